@@ -1,3 +1,4 @@
+const { v4: uuidv4 } = require('uuid');
 const rabbitRequest = require('../../utils/rabbitRequest'); 
 
 const typeDefs = `#graphql
@@ -31,6 +32,15 @@ const typeDefs = `#graphql
         order_items: [OrderItem]!
     }
 
+    input ShippingAddressInput {
+        region: String!
+        comuna: String!
+        street: String!
+        number: String!
+        apartment: String
+        postalCode: String
+    }
+
     type CheckoutResponse {
         order_id: ID!
         status: String!
@@ -46,7 +56,7 @@ const typeDefs = `#graphql
     extend type Mutation {
         addItemToCart(productId: ID!, quantity: Int!): Cart
         removeItemFromCart(productId: ID!): Cart
-        checkout(shippingAddress: String!): CheckoutResponse
+        checkout(shippingAddress: ShippingAddressInput!, idempotencyKey: String): CheckoutResponse
     }
 `;
 
@@ -105,7 +115,7 @@ const resolvers = {
             return await rabbitRequest(rabbitChannel, responseEmitter, 'cart_rpc_queue', payload);
         },
 
-        checkout: async (_, { shippingAddress }, context) => {
+        checkout: async (_, { shippingAddress, idempotencyKey }, context) => {
             const { user_id, shipping_address, rabbitChannel, responseEmitter } = context; 
             
             if (!user_id) {
@@ -116,7 +126,16 @@ const resolvers = {
                 pattern: 'process_checkout',
                 data: { 
                     user_id: user_id,
-                    shipping_address: shippingAddress
+                    shipping_address: {
+                        region: shippingAddress.region,
+                        comuna: shippingAddress.comuna,
+                        street: shippingAddress.street,
+                        number: shippingAddress.number,
+                        apartment: shippingAddress.apartment,
+                        postal_code: shippingAddress.postalCode
+                    },
+                    // El cliente debe reenviar la misma clave al reintentar; sin clave cada intento es un checkout nuevo
+                    idempotency_key: idempotencyKey || uuidv4()
                 } 
             };
             
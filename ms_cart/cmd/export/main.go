@@ -0,0 +1,86 @@
+// Comando de exportación de órdenes para contabilidad.
+//
+//	go run ./cmd/export -from 2024-01-01 -to 2024-02-01 -status PAGADO,ENTREGADO -format csv -out enero.csv
+//
+// Usa las mismas variables DB_* que el servicio. El rango es semiabierto: -to no se incluye.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/export"
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+	"github.com/joho/godotenv"
+)
+
+const dateLayout = "2006-01-02"
+
+func main() {
+	from := flag.String("from", "", "fecha inicial incluida (YYYY-MM-DD)")
+	to := flag.String("to", "", "fecha final excluida (YYYY-MM-DD)")
+	statuses := flag.String("status", "", "estados separados por coma (vacío = todos)")
+	format := flag.String("format", export.FormatCSV, "csv o jsonl")
+	out := flag.String("out", "", "archivo de salida (vacío = stdout)")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Advertencia: No se encontró archivo .env.")
+	}
+
+	exportFormat, err := export.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var query models.OrderQuery
+	if query.CreatedFrom, err = parseDate(*from); err != nil {
+		log.Fatalf("-from inválido: %v", err)
+	}
+	if query.CreatedTo, err = parseDate(*to); err != nil {
+		log.Fatalf("-to inválido: %v", err)
+	}
+	for _, status := range strings.Split(*statuses, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			query.Statuses = append(query.Statuses, models.OrderStatus(status))
+		}
+	}
+
+	output := os.Stdout
+	if *out != "" {
+		output, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("No se pudo crear %s: %v", *out, err)
+		}
+		defer output.Close()
+	}
+
+	// ConectarPostgres informa por stdout: mientras conecta se desvía a stderr para no mezclarlo con el export
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	database.ConectarPostgres()
+	os.Stdout = stdout
+
+	count, err := export.WriteOrders(context.Background(), repository.NewPostgresOrderRepository(), query, exportFormat, output, 0)
+	if err != nil {
+		log.Fatalf("Fallo la exportación después de %d órdenes: %v", count, err)
+	}
+	log.Printf("Exportadas %d órdenes en formato %s", count, exportFormat)
+}
+
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
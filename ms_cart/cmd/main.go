@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/C0kke/FitFashion/ms_cart/internal/eventhandler"
 	"github.com/C0kke/FitFashion/ms_cart/internal/messaging"
@@ -11,6 +13,7 @@ import (
 	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
 	"github.com/C0kke/FitFashion/ms_cart/internal/rpc"
 	"github.com/C0kke/FitFashion/ms_cart/internal/service"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/config"
 	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
 	mqconn "github.com/C0kke/FitFashion/ms_cart/pkg/messaging"
 	"github.com/joho/godotenv"
@@ -57,10 +60,41 @@ func main() {
 
 	cartRepo := repository.NewRedisCartRepository()
 	orderRepo := repository.NewPostgresOrderRepository()
+	couponRepo := repository.NewPostgresCouponRepository()
+	reservationRepo := repository.NewPostgresReservationRepository()
+	productLimitRepo := repository.NewPostgresProductLimitRepository()
 	orderPublisher := messaging.NewOrderPublisher(rabbitConn)
 
-	cartService := service.NewCartService(cartRepo, productClientRPC)
-	orderService := service.NewOrderService(orderRepo, cartRepo, productClientRPC, orderPublisher, paymentClient)
+	promotionService := service.NewPromotionService(couponRepo)
+	reservationService := service.NewReservationService(reservationRepo, productClientRPC)
+	limitService := service.NewLimitService(service.LoadPurchaseLimitsFromEnv(), productLimitRepo, orderRepo)
+	taxCalculator := service.NewTaxCalculator(config.Int("TAX_RATE_PERCENT", service.DefaultTaxRatePercent))
+	cartService := service.NewCartService(cartRepo, productClientRPC, promotionService, limitService, taxCalculator)
+	shippingService := service.NewShippingService(service.LoadShippingRatesFromEnv(), int(config.Int("SHIPPING_ITEM_WEIGHT_GRAMS", 400)), cartService)
+	receiptIssuer := os.Getenv("RECEIPT_ISSUER_NAME")
+	if receiptIssuer == "" {
+		receiptIssuer = service.DefaultReceiptIssuer
+	}
+	receiptService := service.NewReceiptService(repository.NewPostgresReceiptRepository(), orderRepo, receiptIssuer)
+	orderService := service.NewOrderService(orderRepo, cartRepo, productClientRPC, paymentClient, promotionService, reservationService, limitService, shippingService, taxCalculator, repository.NewPostgresRefundRepository(), receiptService)
+	orderService.IdempotencyWindow = config.Duration("CHECKOUT_IDEMPOTENCY_WINDOW", service.DefaultIdempotencyWindow)
+	orderService.PendingOrderTTL = config.Duration("PENDING_ORDER_TTL", service.CheckoutTTL)
+	orderService.ExportMaxOrders = int(config.Int("EXPORT_RPC_MAX_ORDERS", service.DefaultExportMaxOrders))
+
+	shipmentService := service.NewShipmentService(repository.NewPostgresShipmentRepository(), orderRepo)
+	returnService := service.NewReturnService(repository.NewPostgresReturnRepository(), orderService)
+	analyticsService := service.NewAnalyticsService(repository.NewPostgresAnalyticsRepository())
+
+	reservationService.StartExpirySweeper(context.Background(), time.Minute, orderRepo)
+	orderService.StartPendingExpirySweeper(context.Background(), config.Duration("PENDING_ORDER_SWEEP_INTERVAL", time.Minute))
+
+	outboxRelay := service.NewOutboxRelay(repository.NewPostgresOutboxRepository(), orderPublisher)
+	outboxRelay.Start(context.Background(), config.Duration("OUTBOX_RELAY_INTERVAL", 2*time.Second))
+
+	abandonedThreshold := config.Duration("ABANDONED_CART_THRESHOLD", 24*time.Hour)
+	abandonedInterval := config.Duration("ABANDONED_CART_SCAN_INTERVAL", 15*time.Minute)
+	abandonedScanner := service.NewAbandonedCartScanner(cartService, messaging.NewCartPublisher(rabbitConn), abandonedThreshold)
+	abandonedScanner.Start(context.Background(), abandonedInterval)
 
 	paymentListener, err := eventhandler.NewPaymentListener(rabbitConn, orderService) // <--- NUEVO
 	if err != nil {
@@ -69,7 +103,7 @@ func main() {
 	paymentListener.Start()
 
 	rpcQueueName := os.Getenv("RPC_QUEUE_NAME")
-	listener, err := rpc.NewRpcListener(rabbitConn, rpcQueueName, cartService, orderService)
+	listener, err := rpc.NewRpcListener(rabbitConn, rpcQueueName, cartService, orderService, shipmentService, returnService, analyticsService)
 	if err != nil {
 		log.Fatalf("Fallo al configurar RPC Listener: %v", err)
 	}
@@ -77,3 +111,4 @@ func main() {
 	log.Println("MS_CART iniciado y escuchando peticiones RPC...")
 	listener.StartConsuming()
 }
+
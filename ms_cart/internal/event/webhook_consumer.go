@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"errors"
 	"strconv"
-	"strings"
 
 	"github.com/streadway/amqp"
 	"github.com/C0kke/FitFashion/ms_cart/internal/models"
@@ -96,9 +96,25 @@ func (c *WebhookConsumer) handleMessage(d amqp.Delivery) {
 		return
 	}
 
-	nuevoEstado := mapStatus(details.Status)
-	err = c.OrderService.UpdateStatus(context.Background(), uint(orderIDUint), nuevoEstado)
-	
+	nuevoEstado, ok := mapStatus(details.Status)
+	if !ok {
+		log.Printf("Estado MP %s no cambia la orden %d", details.Status, orderIDUint)
+		d.Ack(false)
+		return
+	}
+	err = c.OrderService.UpdateStatus(context.Background(), uint(orderIDUint), nuevoEstado, models.StatusChange{
+		Source:    models.StatusSourceWebhook,
+		PaymentID: paymentID,
+		Reason:    "pago " + details.Status,
+	})
+
+	var invalid *models.InvalidStatusTransitionError
+	if errors.As(err, &invalid) {
+		// Reintentar no va a volver válida la transición
+		log.Printf("Transición ignorada para Orden %d: %v", orderIDUint, err)
+		d.Ack(false)
+		return
+	}
     if err != nil {
 		log.Printf("Error actualizando DB Orden %d: %v", orderIDUint, err)
 		d.Nack(false, true) 
@@ -108,11 +124,12 @@ func (c *WebhookConsumer) handleMessage(d amqp.Delivery) {
 	d.Ack(false) 
 }
 
-func mapStatus(mpStatus string) string {
+// mapStatus traduce el estado de MP; los estados intermedios (pending, in_process) no cambian la orden
+func mapStatus(mpStatus string) (models.OrderStatus, bool) {
 	switch mpStatus {
-	case "approved": return "PAGADO"
-	case "rejected", "cancelled": return "CANCELADO"
-	case "in_process", "pending": return "PENDIENTE"
-	default: return strings.ToUpper(mpStatus)
+	case "approved": return models.OrderStatusPagado, true
+	case "rejected": return models.OrderStatusRechazado, true
+	case "cancelled": return models.OrderStatusCancelado, true
+	default: return "", false
 	}
 }
\ No newline at end of file
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+
+	// pageSize es cuántas órdenes se leen por consulta mientras se escribe el export
+	pageSize = models.MaxOrderPageSize
+)
+
+// csvColumns es el layout fijo del CSV: una fila por línea de la orden con los datos de la orden repetidos.
+// Solo se agregan columnas al final para no romper las planillas de finanzas.
+var csvColumns = []string{
+	"order_id", "created_at", "status", "user_id", "payment_id", "coupon_code",
+	"order_subtotal", "order_discount", "order_shipping", "order_net", "order_tax", "order_total",
+	"item_id", "product_id", "product_name", "size", "color", "bundle_id",
+	"quantity", "unit_price", "line_total", "line_net", "line_tax",
+}
+
+// OrderRecord es el formato de cada línea del JSON Lines: la orden con sus items anidados
+type OrderRecord struct {
+	OrderID    uint         `json:"order_id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Status     string       `json:"status"`
+	UserID     uint         `json:"user_id"`
+	PaymentID  string       `json:"payment_id"`
+	CouponCode string       `json:"coupon_code"`
+	Subtotal   int64        `json:"subtotal"`
+	Discount   int64        `json:"discount"`
+	Shipping   int64        `json:"shipping"`
+	Net        int64        `json:"net"`
+	Tax        int64        `json:"tax"`
+	Total      int64        `json:"total"`
+	Items      []ItemRecord `json:"items"`
+}
+
+type ItemRecord struct {
+	ItemID      uint   `json:"item_id"`
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Size        string `json:"size"`
+	Color       string `json:"color"`
+	BundleID    string `json:"bundle_id"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   int64  `json:"unit_price"`
+	LineTotal   int64  `json:"line_total"`
+	LineNet     int64  `json:"line_net"`
+	LineTax     int64  `json:"line_tax"`
+}
+
+// ParseFormat valida el formato pedido (csv por defecto)
+func ParseFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatJSONL:
+		return format, nil
+	default:
+		return "", fmt.Errorf("formato de exportación inválido: %s (usa csv o jsonl)", format)
+	}
+}
+
+// ContentType devuelve el tipo MIME del formato
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// WriteOrders recorre las órdenes que cumplen query en orden de creación y las escribe en w página por
+// página, sin cargarlas todas en memoria. maxOrders > 0 corta con error al superarlo. Devuelve cuántas
+// órdenes escribió.
+func WriteOrders(ctx context.Context, repo repository.OrderRepository, query models.OrderQuery, format string, w io.Writer, maxOrders int) (int, error) {
+	query.SortBy = models.OrderSortCreatedAt
+	query.SortDir = "asc"
+	query.Limit = pageSize
+	query.Cursor = ""
+	if err := query.Normalize(); err != nil {
+		return 0, err
+	}
+
+	var csvWriter *csv.Writer
+	var jsonEncoder *json.Encoder
+	if format == FormatJSONL {
+		jsonEncoder = json.NewEncoder(w)
+	} else {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(csvColumns); err != nil {
+			return 0, err
+		}
+	}
+
+	written := 0
+	for {
+		page, err := repo.FindPage(ctx, &query)
+		if err != nil {
+			return written, err
+		}
+
+		for i := range page.Orders {
+			if maxOrders > 0 && written >= maxOrders {
+				return written, fmt.Errorf("el export supera el máximo de %d órdenes; acota el rango de fechas o usa el comando de exportación", maxOrders)
+			}
+			record := newOrderRecord(&page.Orders[i])
+			if jsonEncoder != nil {
+				err = jsonEncoder.Encode(record)
+			} else {
+				err = writeCSVRows(csvWriter, record)
+			}
+			if err != nil {
+				return written, err
+			}
+			written++
+		}
+
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return written, err
+			}
+		}
+		if !page.HasMore {
+			return written, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func newOrderRecord(order *models.Order) OrderRecord {
+	record := OrderRecord{
+		OrderID:    order.ID,
+		CreatedAt:  order.CreatedAt.UTC(),
+		Status:     string(order.Status),
+		UserID:     order.UserID,
+		PaymentID:  order.PaymentID,
+		CouponCode: order.CouponCode,
+		Subtotal:   order.Subtotal,
+		Discount:   order.DiscountTotal,
+		Shipping:   order.ShippingCost,
+		Net:        order.NetTotal,
+		Tax:        order.TaxTotal,
+		Total:      order.Total,
+		Items:      make([]ItemRecord, 0, len(order.OrderItems)),
+	}
+	for _, item := range order.OrderItems {
+		record.Items = append(record.Items, ItemRecord{
+			ItemID:      item.ID,
+			ProductID:   item.ProductID,
+			ProductName: item.NameSnapshot,
+			Size:        item.Size,
+			Color:       item.Color,
+			BundleID:    item.BundleID,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			LineTotal:   item.UnitPrice * int64(item.Quantity),
+			LineNet:     item.NetAmount,
+			LineTax:     item.TaxAmount,
+		})
+	}
+	return record
+}
+
+func writeCSVRows(w *csv.Writer, record OrderRecord) error {
+	orderColumns := []string{
+		strconv.FormatUint(uint64(record.OrderID), 10),
+		record.CreatedAt.Format(time.RFC3339),
+		record.Status,
+		strconv.FormatUint(uint64(record.UserID), 10),
+		record.PaymentID,
+		record.CouponCode,
+		strconv.FormatInt(record.Subtotal, 10),
+		strconv.FormatInt(record.Discount, 10),
+		strconv.FormatInt(record.Shipping, 10),
+		strconv.FormatInt(record.Net, 10),
+		strconv.FormatInt(record.Tax, 10),
+		strconv.FormatInt(record.Total, 10),
+	}
+
+	// Una orden sin items igual aparece, con las columnas de item vacías
+	if len(record.Items) == 0 {
+		return w.Write(append(orderColumns, make([]string, len(csvColumns)-len(orderColumns))...))
+	}
+
+	for _, item := range record.Items {
+		row := append(append([]string{}, orderColumns...),
+			strconv.FormatUint(uint64(item.ItemID), 10),
+			item.ProductID,
+			item.ProductName,
+			item.Size,
+			item.Color,
+			item.BundleID,
+			strconv.Itoa(item.Quantity),
+			strconv.FormatInt(item.UnitPrice, 10),
+			strconv.FormatInt(item.LineTotal, 10),
+			strconv.FormatInt(item.LineNet, 10),
+			strconv.FormatInt(item.LineTax, 10),
+		)
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
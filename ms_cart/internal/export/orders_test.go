@@ -0,0 +1,192 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// pagedOrderRepository devuelve pages en orden, una por llamada a FindPage, usando el cursor como índice
+type pagedOrderRepository struct {
+	repository.OrderRepository
+	pages   [][]models.Order
+	queries []models.OrderQuery
+}
+
+func (r *pagedOrderRepository) FindPage(ctx context.Context, query *models.OrderQuery) (*models.OrderPage, error) {
+	r.queries = append(r.queries, *query)
+	index := len(r.queries) - 1
+	page := &models.OrderPage{Orders: r.pages[index], Limit: query.Limit}
+	if index < len(r.pages)-1 {
+		page.HasMore = true
+		page.NextCursor = "cursor-" + string(rune('a'+index))
+	}
+	return page, nil
+}
+
+func testOrders() [][]models.Order {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	first := models.Order{
+		UserID: 7, Status: models.OrderStatusPagado, PaymentID: "pay-1", CouponCode: "VERANO",
+		Subtotal: 11900, DiscountTotal: 1190, ShippingCost: 0, NetTotal: 9000, TaxTotal: 1710, Total: 10710,
+		OrderItems: []models.OrderItem{
+			{ProductID: "p1", NameSnapshot: "Polera", Size: "M", Color: "negro", Quantity: 2, UnitPrice: 2975, NetAmount: 5000, TaxAmount: 950},
+			{ProductID: "p2", NameSnapshot: "Jeans, slim", Size: "42", Quantity: 1, UnitPrice: 5950, NetAmount: 5000, TaxAmount: 950},
+		},
+	}
+	first.ID, first.CreatedAt = 1, created
+	first.OrderItems[0].ID, first.OrderItems[1].ID = 10, 11
+
+	empty := models.Order{UserID: 8, Status: models.OrderStatusCancelado, Total: 0}
+	empty.ID, empty.CreatedAt = 2, created.Add(time.Hour)
+
+	return [][]models.Order{{first}, {empty}}
+}
+
+func TestWriteOrdersCSV(t *testing.T) {
+	repo := &pagedOrderRepository{pages: testOrders()}
+	var out bytes.Buffer
+
+	written, err := WriteOrders(context.Background(), repo, models.OrderQuery{SortBy: models.OrderSortTotal, Cursor: "viejo"}, FormatCSV, &out, 0)
+	if err != nil {
+		t.Fatalf("WriteOrders: %v", err)
+	}
+	if written != 2 {
+		t.Errorf("written = %d, want 2", written)
+	}
+
+	if len(repo.queries) != 2 {
+		t.Fatalf("FindPage llamado %d veces, want 2", len(repo.queries))
+	}
+	if q := repo.queries[0]; q.SortBy != models.OrderSortCreatedAt || q.SortDir != "asc" || q.Cursor != "" || q.Limit != pageSize {
+		t.Errorf("primera consulta = %+v, want created_at asc sin cursor", q)
+	}
+	if repo.queries[1].Cursor != "cursor-a" {
+		t.Errorf("segunda consulta cursor = %q, want cursor-a", repo.queries[1].Cursor)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV inválido: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("filas = %d, want header + 2 items + 1 orden sin items", len(rows))
+	}
+	if strings.Join(rows[0], ",") != strings.Join(csvColumns, ",") {
+		t.Errorf("header = %v", rows[0])
+	}
+	for i, row := range rows {
+		if len(row) != len(csvColumns) {
+			t.Errorf("fila %d tiene %d columnas, want %d", i, len(row), len(csvColumns))
+		}
+	}
+
+	column := func(row []string, name string) string {
+		for i, c := range csvColumns {
+			if c == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("columna %s no existe", name)
+		return ""
+	}
+	if got := column(rows[1], "created_at"); got != "2026-03-01T12:00:00Z" {
+		t.Errorf("created_at = %s", got)
+	}
+	if got := column(rows[1], "line_total"); got != "5950" {
+		t.Errorf("line_total = %s, want 5950", got)
+	}
+	if got := column(rows[2], "product_name"); got != "Jeans, slim" {
+		t.Errorf("product_name = %s", got)
+	}
+	if got := column(rows[2], "order_total"); got != "10710" {
+		t.Errorf("order_total repetido = %s, want 10710", got)
+	}
+	if column(rows[3], "order_id") != "2" || column(rows[3], "item_id") != "" || column(rows[3], "quantity") != "" {
+		t.Errorf("orden sin items = %v", rows[3])
+	}
+}
+
+func TestWriteOrdersJSONL(t *testing.T) {
+	repo := &pagedOrderRepository{pages: testOrders()}
+	var out bytes.Buffer
+
+	written, err := WriteOrders(context.Background(), repo, models.OrderQuery{}, FormatJSONL, &out, 0)
+	if err != nil || written != 2 {
+		t.Fatalf("WriteOrders = %d, %v", written, err)
+	}
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("líneas = %d, want 2", len(lines))
+	}
+	var records []OrderRecord
+	for _, line := range lines {
+		var record OrderRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("línea inválida %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if records[0].OrderID != 1 || len(records[0].Items) != 2 || records[0].Items[0].LineTotal != 5950 {
+		t.Errorf("primera orden = %+v", records[0])
+	}
+	if records[1].OrderID != 2 || records[1].Items == nil || len(records[1].Items) != 0 {
+		t.Errorf("orden sin items debe traer items vacío, got %+v", records[1])
+	}
+}
+
+func TestWriteOrdersMaxOrders(t *testing.T) {
+	repo := &pagedOrderRepository{pages: testOrders()}
+	var out bytes.Buffer
+
+	written, err := WriteOrders(context.Background(), repo, models.OrderQuery{}, FormatJSONL, &out, 1)
+	if err == nil {
+		t.Fatal("se esperaba error al superar maxOrders")
+	}
+	if written != 1 {
+		t.Errorf("written = %d, want 1", written)
+	}
+}
+
+func TestWriteOrdersInvalidQuery(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(-time.Hour)
+	repo := &pagedOrderRepository{pages: testOrders()}
+
+	if _, err := WriteOrders(context.Background(), repo, models.OrderQuery{CreatedFrom: &from, CreatedTo: &to}, FormatCSV, &bytes.Buffer{}, 0); err == nil {
+		t.Fatal("se esperaba error con rango de fechas invertido")
+	}
+	if len(repo.queries) != 0 {
+		t.Error("no debe consultar el repositorio con una query inválida")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	cases := map[string]string{"": FormatCSV, "csv": FormatCSV, " JSONL ": FormatJSONL}
+	for input, want := range cases {
+		got, err := ParseFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Error("ParseFormat(xlsx) debe fallar")
+	}
+}
+
+func TestContentType(t *testing.T) {
+	if got := ContentType(FormatJSONL); got != "application/x-ndjson" {
+		t.Errorf("ContentType(jsonl) = %s", got)
+	}
+	if got := ContentType(FormatCSV); got != "text/csv; charset=utf-8" {
+		t.Errorf("ContentType(csv) = %s", got)
+	}
+}
@@ -0,0 +1,67 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/streadway/amqp"
+)
+
+const CartEventsExchange = "cart_events"
+
+type CartPublisher struct {
+	conn *amqp.Connection
+}
+
+func NewCartPublisher(conn *amqp.Connection) *CartPublisher {
+	return &CartPublisher{
+		conn: conn,
+	}
+}
+
+// Publish envía un evento de carrito al exchange topic usando routingKey (ej: cart.abandoned)
+func (p *CartPublisher) Publish(ctx context.Context, routingKey string, payload interface{}) error {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	err = ch.ExchangeDeclare(
+		CartEventsExchange,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error al serializar evento %s: %w", routingKey, err)
+	}
+
+	err = ch.Publish(
+		CartEventsExchange,
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("fallo al publicar evento %s: %w", routingKey, err)
+	}
+
+	log.Printf("Evento '%s' publicado", routingKey)
+	return nil
+}
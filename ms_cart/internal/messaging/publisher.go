@@ -2,14 +2,17 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"fmt"
 	"time"
 
 	"github.com/streadway/amqp"
-	"github.com/C0kke/FitFashion/ms_cart/internal/models"
 )
 
+const OrderEventsExchange = "order_events"
+
+// confirmTimeout es lo máximo que se espera el ack del broker por cada mensaje
+const confirmTimeout = 5 * time.Second
+
 type OrderPublisher struct {
 	conn *amqp.Connection
 }
@@ -20,8 +23,9 @@ func NewOrderPublisher(conn *amqp.Connection) *OrderPublisher {
 	}
 }
 
-func (p *OrderPublisher) PublishOrderCreated(ctx context.Context, order *models.Order) error {
-	
+// PublishConfirmed publica un evento de orden y espera la confirmación del broker (publisher confirms).
+// Devuelve error si el broker hace nack o no responde a tiempo, para que el outbox lo reintente.
+func (p *OrderPublisher) PublishConfirmed(ctx context.Context, eventType string, messageID string, body []byte) error {
 	ch, err := p.conn.Channel()
 	if err != nil {
 		return err
@@ -29,42 +33,56 @@ func (p *OrderPublisher) PublishOrderCreated(ctx context.Context, order *models.
 	defer ch.Close()
 
 	err = ch.ExchangeDeclare(
-		"order_events", 
-		"fanout",    
-		true,      
-		false, 
-		false,   
-		false,      
+		OrderEventsExchange,
+		"fanout",
+		true,
+		false,
+		false,
+		false,
 		nil,
 	)
 	if err != nil {
 		return err
 	}
 
-	messageBody := map[string]interface{}{
-		"order_id":    order.ID,
-		"user_id":     order.UserID,
-		"total":       order.Total,
-		"items":       order.OrderItems,
-        "timestamp":   time.Now(),
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("el canal no admite publisher confirms: %w", err)
 	}
-	body, _ := json.Marshal(messageBody)
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
 
 	err = ch.Publish(
-		"order_events", 
-		"",         
-		false,      
-		false,       
+		OrderEventsExchange,
+		eventType,
+		false,
+		false,
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageID,
+			Type:         eventType,
+			Timestamp:    time.Now(),
+			Body:         body,
 		},
 	)
 	if err != nil {
-		log.Printf("Advertencia: Fallo al publicar evento order.created: %v", err)
-	} else {
-        log.Printf("Evento 'order.created' publicado para la orden #%d", order.ID)
-    }
+		return fmt.Errorf("fallo al publicar evento %s: %w", eventType, err)
+	}
 
-	return nil
-}
\ No newline at end of file
+	timer := time.NewTimer(confirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-confirms:
+		if !ok {
+			return fmt.Errorf("el canal se cerró antes de confirmar el evento %s", eventType)
+		}
+		if !confirm.Ack {
+			return fmt.Errorf("el broker rechazó el evento %s", eventType)
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("sin confirmación del broker para el evento %s después de %s", eventType, confirmTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
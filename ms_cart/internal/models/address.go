@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Address es la dirección de despacho estructurada; se guarda embebida en la orden
+type Address struct {
+	Region     string `json:"region" gorm:"type:varchar(80)"`
+	Comuna     string `json:"comuna" gorm:"type:varchar(80)"`
+	Street     string `json:"street" gorm:"type:varchar(150)"`
+	Number     string `json:"number" gorm:"type:varchar(20)"`
+	Apartment  string `json:"apartment,omitempty" gorm:"type:varchar(40)"`
+	PostalCode string `json:"postal_code,omitempty" gorm:"type:varchar(20)"`
+}
+
+func (a Address) Validate() error {
+	var missing []string
+	if strings.TrimSpace(a.Region) == "" {
+		missing = append(missing, "region")
+	}
+	if strings.TrimSpace(a.Comuna) == "" {
+		missing = append(missing, "comuna")
+	}
+	if strings.TrimSpace(a.Street) == "" {
+		missing = append(missing, "street")
+	}
+	if strings.TrimSpace(a.Number) == "" {
+		missing = append(missing, "number")
+	}
+	if len(missing) > 0 {
+		return errors.New("dirección incompleta, faltan: " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// String arma la dirección en una línea, como se mostraba antes en ShippingAddress
+func (a Address) String() string {
+	line := fmt.Sprintf("%s %s", a.Street, a.Number)
+	if a.Apartment != "" {
+		line += ", " + a.Apartment
+	}
+	line += fmt.Sprintf(", %s, %s", a.Comuna, a.Region)
+	if a.PostalCode != "" {
+		line += " (" + a.PostalCode + ")"
+	}
+	return line
+}
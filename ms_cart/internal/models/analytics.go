@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AnalyticsGranularityDay   = "day"
+	AnalyticsGranularityWeek  = "week"
+	AnalyticsGranularityMonth = "month"
+
+	AnalyticsSortUnits   = "units"
+	AnalyticsSortRevenue = "revenue"
+
+	DefaultTopProductsLimit = 10
+	MaxTopProductsLimit     = 100
+)
+
+// SoldOrderStatuses son los estados de una orden cuyo pago se aprobó y se entregó (o se va a entregar).
+// REEMBOLSADO cuenta como venta en el bruto y se descuenta en el neto vía la tabla de reembolsos.
+var SoldOrderStatuses = []OrderStatus{
+	OrderStatusPagado,
+	OrderStatusEnviado,
+	OrderStatusEntregado,
+	OrderStatusReembolsoParcial,
+	OrderStatusReembolsado,
+}
+
+// AnalyticsQuery son los filtros comunes de las consultas de analítica.
+// El rango es semiabierto igual que OrderQuery y se aplica sobre la fecha de creación de la orden.
+type AnalyticsQuery struct {
+	CreatedFrom *time.Time `json:"created_from"`
+	CreatedTo   *time.Time `json:"created_to"`
+	Granularity string     `json:"granularity"` // day (default), week o month
+	Timezone    string     `json:"timezone"`    // zona IANA para cortar los períodos; UTC por defecto
+	SortBy      string     `json:"sort_by"`     // top productos: units (default) o revenue
+	Limit       int        `json:"limit"`
+}
+
+// Normalize completa los valores por defecto y valida los filtros
+func (q *AnalyticsQuery) Normalize() error {
+	q.Granularity = strings.ToLower(strings.TrimSpace(q.Granularity))
+	if q.Granularity == "" {
+		q.Granularity = AnalyticsGranularityDay
+	}
+	switch q.Granularity {
+	case AnalyticsGranularityDay, AnalyticsGranularityWeek, AnalyticsGranularityMonth:
+	default:
+		return fmt.Errorf("granularity inválida: %s (usa day, week o month)", q.Granularity)
+	}
+
+	q.Timezone = strings.TrimSpace(q.Timezone)
+	if q.Timezone == "" {
+		q.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("timezone inválida: %s", q.Timezone)
+	}
+
+	q.SortBy = strings.ToLower(strings.TrimSpace(q.SortBy))
+	if q.SortBy == "" {
+		q.SortBy = AnalyticsSortUnits
+	}
+	if q.SortBy != AnalyticsSortUnits && q.SortBy != AnalyticsSortRevenue {
+		return fmt.Errorf("sort_by inválido: %s (usa units o revenue)", q.SortBy)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultTopProductsLimit
+	}
+	if q.Limit > MaxTopProductsLimit {
+		q.Limit = MaxTopProductsLimit
+	}
+
+	if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+		return fmt.Errorf("created_from debe ser anterior a created_to")
+	}
+	return nil
+}
+
+// SalesTotals son las cifras de ventas de un período o del rango completo.
+// Los montos van en pesos enteros igual que las órdenes.
+type SalesTotals struct {
+	Orders            int64 `json:"orders"`
+	Revenue           int64 `json:"revenue"`
+	Refunded          int64 `json:"refunded"`
+	NetRevenue        int64 `json:"net_revenue"`
+	AverageOrderValue int64 `json:"average_order_value"`
+}
+
+// Complete calcula los campos derivados a partir de órdenes, ingresos y reembolsos
+func (b *SalesTotals) Complete() {
+	b.NetRevenue = b.Revenue - b.Refunded
+	b.AverageOrderValue = 0
+	if b.Orders > 0 {
+		b.AverageOrderValue = b.Revenue / b.Orders
+	}
+}
+
+// SalesBucket son las ventas de un período que parte en Period
+type SalesBucket struct {
+	Period time.Time `json:"period"`
+	SalesTotals
+}
+
+type SalesSummary struct {
+	Granularity string        `json:"granularity"`
+	Timezone    string        `json:"timezone"`
+	Buckets     []SalesBucket `json:"buckets"`
+	Totals      SalesTotals   `json:"totals"`
+}
+
+type ProductSales struct {
+	ProductID    string `json:"product_id"`
+	NameSnapshot string `json:"name"`
+	Units        int64  `json:"units"`
+	Orders       int64  `json:"orders"`
+	Revenue      int64  `json:"revenue"`
+}
+
+// ConversionStats resume qué pasó con las órdenes creadas en el rango
+type ConversionStats struct {
+	Created     int64 `json:"created"`
+	Paid        int64 `json:"paid"`
+	StockFailed int64 `json:"stock_failed"`
+	Pending     int64 `json:"pending"`
+	Expired     int64 `json:"expired"`
+	Cancelled   int64 `json:"cancelled"`
+	// ConversionRate es la fracción de órdenes creadas que llegaron a PAGADO
+	ConversionRate float64 `json:"conversion_rate"`
+	// StockFailureRate es la fracción de órdenes creadas que terminaron en STOCK_FALLIDO en algún momento
+	StockFailureRate float64 `json:"stock_failure_rate"`
+}
+
+// Complete calcula las tasas a partir de los conteos
+func (c *ConversionStats) Complete() {
+	c.ConversionRate, c.StockFailureRate = 0, 0
+	if c.Created > 0 {
+		c.ConversionRate = float64(c.Paid) / float64(c.Created)
+		c.StockFailureRate = float64(c.StockFailed) / float64(c.Created)
+	}
+}
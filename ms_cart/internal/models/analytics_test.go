@@ -0,0 +1,69 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsQueryNormalizeDefaults(t *testing.T) {
+	q := AnalyticsQuery{}
+	if err := q.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if q.Granularity != AnalyticsGranularityDay || q.Timezone != "UTC" || q.SortBy != AnalyticsSortUnits || q.Limit != DefaultTopProductsLimit {
+		t.Errorf("defaults = %+v", q)
+	}
+
+	q = AnalyticsQuery{Granularity: " Month ", Timezone: " America/Santiago ", SortBy: "REVENUE", Limit: 1000}
+	if err := q.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if q.Granularity != AnalyticsGranularityMonth || q.Timezone != "America/Santiago" || q.SortBy != AnalyticsSortRevenue || q.Limit != MaxTopProductsLimit {
+		t.Errorf("normalizado = %+v", q)
+	}
+}
+
+func TestAnalyticsQueryNormalizeErrors(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	same := from
+	cases := map[string]AnalyticsQuery{
+		"granularity": {Granularity: "year"},
+		"timezone":    {Timezone: "Marte/Olympus"},
+		"sort_by":     {SortBy: "orders"},
+		"rango vacío": {CreatedFrom: &from, CreatedTo: &same},
+	}
+	for name, q := range cases {
+		if err := q.Normalize(); err == nil {
+			t.Errorf("%s: se esperaba error", name)
+		}
+	}
+}
+
+func TestSalesTotalsComplete(t *testing.T) {
+	totals := SalesTotals{Orders: 3, Revenue: 30000, Refunded: 5000, AverageOrderValue: 99}
+	totals.Complete()
+	if totals.NetRevenue != 25000 || totals.AverageOrderValue != 10000 {
+		t.Errorf("Complete = %+v", totals)
+	}
+
+	empty := SalesTotals{Refunded: 1000, AverageOrderValue: 99}
+	empty.Complete()
+	if empty.NetRevenue != -1000 || empty.AverageOrderValue != 0 {
+		t.Errorf("sin órdenes = %+v", empty)
+	}
+}
+
+func TestConversionStatsComplete(t *testing.T) {
+	stats := ConversionStats{Created: 8, Paid: 6, StockFailed: 1}
+	stats.Complete()
+	if math.Abs(stats.ConversionRate-0.75) > 1e-9 || math.Abs(stats.StockFailureRate-0.125) > 1e-9 {
+		t.Errorf("Complete = %+v", stats)
+	}
+
+	empty := ConversionStats{ConversionRate: 1, StockFailureRate: 1}
+	empty.Complete()
+	if empty.ConversionRate != 0 || empty.StockFailureRate != 0 {
+		t.Errorf("sin órdenes las tasas deben ser 0, got %+v", empty)
+	}
+}
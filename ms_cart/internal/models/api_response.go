@@ -2,6 +2,50 @@ package models
 
 type CheckoutResponse struct {
     OrderID uint `json:"order_id"`
-    Status string `json:"status"` //pendiente
+    Status OrderStatus `json:"status"` //pendiente
     PaymentURL string `json:"payment_url"` 
-}
\ No newline at end of file
+}
+
+// RejectedCartItem es una línea del carrito de invitado que no se pudo fusionar; Quantity son las
+// unidades descartadas
+type RejectedCartItem struct {
+	ProductID string `json:"product_id"`
+	Variant
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+}
+
+type MergeCartResult struct {
+	Cart          *Cart              `json:"cart"`
+	RejectedItems []RejectedCartItem `json:"rejected_items"`
+}
+
+// PriceChange describe una línea cuyo precio cambió desde que se agregó al carrito
+type PriceChange struct {
+	ProductID string `json:"product_id"`
+	Variant
+	BundleID string `json:"bundle_id,omitempty"`
+	OldPrice int    `json:"old_price"`
+	NewPrice int    `json:"new_price"`
+}
+
+const (
+	LineAccepted = "ACCEPTED"
+	LineClamped  = "CLAMPED"
+	LineRejected = "REJECTED"
+)
+
+// CartLineResult es el resultado por línea de set_cart_items
+type CartLineResult struct {
+	ProductID string `json:"product_id"`
+	Variant
+	Requested int    `json:"requested"`
+	Quantity  int    `json:"quantity"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type SetCartItemsResult struct {
+	Cart  *Cart            `json:"cart"`
+	Lines []CartLineResult `json:"lines"`
+}
@@ -1,15 +1,108 @@
 package models
 
-import "time"
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// guestCartPrefix distingue los carritos de invitado (sesión) de los de usuarios registrados
+const guestCartPrefix = "guest:"
+
+// Variant identifica la talla y el color de una línea; vacío significa producto sin variantes
+type Variant struct {
+	Size  string `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// Normalize quita espacios y unifica mayúsculas para comparar variantes de forma estable
+func (v Variant) Normalize() Variant {
+	return Variant{
+		Size:  strings.ToUpper(strings.TrimSpace(v.Size)),
+		Color: strings.ToLower(strings.TrimSpace(v.Color)),
+	}
+}
+
+// LineKey identifica una línea de carrito por producto + variante
+func LineKey(productID string, v Variant) string {
+	n := v.Normalize()
+	return productID + "|" + n.Size + "|" + n.Color
+}
 
 // redis
 type CartItem struct {
 	ProductID string `json:"product_id"`
+	Variant
 	Quantity   int    `json:"quantity"`
+	// BundleID agrupa las prendas agregadas juntas como outfit
+	BundleID string `json:"bundle_id,omitempty"`
+	// UnitPrice es el precio unitario que vio el usuario al agregar el producto
+	UnitPrice int `json:"unit_price,omitempty"`
+}
+
+// BundleIDFor genera un ID estable para un outfit a partir de sus prendas, sin importar el orden
+func BundleIDFor(pieces []CartItem) string {
+	keys := make([]string, len(pieces))
+	for i, piece := range pieces {
+		keys[i] = LineKey(piece.ProductID, piece.Variant)
+	}
+	sort.Strings(keys)
+	sum := sha1.Sum([]byte(strings.Join(keys, ",")))
+	return "outfit-" + hex.EncodeToString(sum[:8])
+}
+
+func (i CartItem) LineKey() string {
+	if i.BundleID != "" {
+		return LineKey(i.ProductID, i.Variant) + "|" + i.BundleID
+	}
+	return LineKey(i.ProductID, i.Variant)
 }
 
 type Cart struct {
-	UserID    int        `json:"user_id"`
+	// UserID es el dueño opaco del carrito: el ID del usuario o "guest:<sesión>" para invitados
+	UserID    string        `json:"user_id"`
 	Items []CartItem `json:"items"`
     LastUpdated time.Time `json:"last_updated"`
-}
\ No newline at end of file
+	CouponCode string `json:"coupon_code,omitempty"`
+	// Version se incrementa en cada guardado y permite detectar escrituras concurrentes
+	Version int64 `json:"version"`
+}
+
+// GuestCartID construye el ID de carrito para una sesión anónima
+func GuestCartID(sessionID string) string {
+	return guestCartPrefix + sessionID
+}
+
+// IsGuestCartID indica si el ID corresponde a un carrito de invitado
+func IsGuestCartID(cartID string) bool {
+	return strings.HasPrefix(cartID, guestCartPrefix)
+}
+
+// UnmarshalJSON acepta carritos guardados cuando user_id todavía era numérico
+func (c *Cart) UnmarshalJSON(data []byte) error {
+	type cartAlias Cart
+	aux := struct {
+		UserID interface{} `json:"user_id"`
+		*cartAlias
+	}{cartAlias: (*cartAlias)(c)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	switch v := aux.UserID.(type) {
+	case string:
+		c.UserID = v
+	case float64:
+		c.UserID = fmt.Sprintf("%.0f", v)
+	case nil:
+		c.UserID = ""
+	default:
+		return fmt.Errorf("user_id de carrito con tipo inesperado: %T", v)
+	}
+	return nil
+}
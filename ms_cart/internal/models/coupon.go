@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CouponTypePercentage = "PERCENTAGE"
+	CouponTypeFixed      = "FIXED"
+)
+
+// postgreSQL
+type Coupon struct {
+	gorm.Model
+
+	Code           string     `gorm:"uniqueIndex;not null" json:"code"`
+	Type           string     `gorm:"not null" json:"type"`
+	Value          int64      `gorm:"not null" json:"value"` // porcentaje (1-100) o monto fijo en CLP
+	MinSpend       int64      `gorm:"default:0" json:"min_spend"`
+	MaxUsesPerUser int        `gorm:"default:0" json:"max_uses_per_user"` // 0 = sin límite
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Active         bool       `gorm:"default:true" json:"active"`
+}
+
+// postgreSQL
+type CouponRedemption struct {
+	gorm.Model
+
+	CouponID uint `gorm:"not null;index"`
+	UserID   uint `gorm:"not null;index"`
+	OrderID  uint `gorm:"not null;index"`
+}
+
+// DiscountLine es el descuento aplicado que se muestra en el carrito
+type DiscountLine struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Amount      int64  `json:"amount"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -9,8 +11,26 @@ type Order struct {
     gorm.Model 
 	
 	UserID   uint      `gorm:"not null;index"` 
+	Subtotal    int64   `gorm:"type:numeric;default:0"`
+	DiscountTotal int64 `gorm:"type:numeric;default:0"`
+	CouponCode  string  `gorm:"type:varchar(50)"`
 	Total       int64   `gorm:"type:numeric"`
-	Status      string    `gorm:"default:'PENDING'"`
+	Status      OrderStatus `gorm:"type:varchar(30);default:'PENDIENTE';index"`
 	ShippingAddress string   `gorm:"type:text;not null"`
+	ShippingDetails Address `gorm:"embedded;embeddedPrefix:shipping_"`
+	ShippingCost int64 `gorm:"type:numeric;default:0"`
+	ShippingRateName string `gorm:"type:varchar(80)"`
+	TaxRatePercent int64 `gorm:"default:0"`
+	NetTotal    int64   `gorm:"type:numeric;default:0"`
+	TaxTotal    int64   `gorm:"type:numeric;default:0"`
+	// IdempotencyKey es la clave enviada por el cliente en process_checkout; PaymentURL permite repetir la respuesta original
+	IdempotencyKey string `gorm:"type:varchar(100);index"`
+	PaymentURL  string  `gorm:"type:text"`
+	// PaymentID es el pago de Mercado Pago que aprobó la orden; se usa para reembolsar
+	PaymentID   string  `gorm:"type:varchar(50);index"`
+	// ExpiresAt es el plazo para pagar; vencido, la orden pasa a EXPIRADO y la preferencia de MP deja de aceptar pagos
+	ExpiresAt   *time.Time `gorm:"index"`
+	// ExchangeForOrderID apunta a la orden original cuando esta orden es un cambio de talla/color
+	ExchangeForOrderID *uint `gorm:"index"`
 	OrderItems  []OrderItem `gorm:"foreignKey:OrderID"` 
 }
\ No newline at end of file
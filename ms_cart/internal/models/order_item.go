@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"strings"
+
 	"gorm.io/gorm"
 )
 
@@ -11,6 +14,26 @@ type OrderItem struct {
 	OrderID        uint    `gorm:"index"`
 	ProductID     string  `gorm:"not null"`
 	NameSnapshot string  `gorm:"not null"` 
+	Size         string  `gorm:"type:varchar(20)"`
+	Color        string  `gorm:"type:varchar(40)"`
+	BundleID     string  `gorm:"type:varchar(64);index"`
 	UnitPrice int64 `gorm:"type:numeric"`
 	Quantity       int     `gorm:"not null"`
-}
\ No newline at end of file
+	NetAmount    int64   `gorm:"type:numeric;default:0"` // neto del subtotal de la línea
+	TaxAmount    int64   `gorm:"type:numeric;default:0"` // IVA del subtotal de la línea
+}
+
+// DisplayName agrega la variante al nombre para mostrarla en pagos y comprobantes
+func (i OrderItem) DisplayName() string {
+	var parts []string
+	if i.Size != "" {
+		parts = append(parts, "Talla "+i.Size)
+	}
+	if i.Color != "" {
+		parts = append(parts, "Color "+i.Color)
+	}
+	if len(parts) == 0 {
+		return i.NameSnapshot
+	}
+	return fmt.Sprintf("%s (%s)", i.NameSnapshot, strings.Join(parts, ", "))
+}
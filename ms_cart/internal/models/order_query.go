@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	OrderSortCreatedAt = "created_at"
+	OrderSortTotal     = "total"
+
+	DefaultOrderPageSize = 20
+	MaxOrderPageSize     = 100
+)
+
+// OrderQuery son los filtros, orden y paginación de get_all_orders y get_user_orders.
+// El rango de fechas es semiabierto: created_from <= created_at < created_to.
+type OrderQuery struct {
+	UserID      uint          `json:"-"`
+	Statuses    []OrderStatus `json:"status"`
+	CreatedFrom *time.Time    `json:"created_from"`
+	CreatedTo   *time.Time    `json:"created_to"`
+	MinTotal    *int64        `json:"min_total"`
+	MaxTotal    *int64        `json:"max_total"`
+	ProductID   string        `json:"product_id"`
+	SortBy      string        `json:"sort_by"`  // created_at (default) o total
+	SortDir     string        `json:"sort_dir"` // desc (default) o asc
+	Limit       int           `json:"limit"`
+	Cursor      string        `json:"cursor"` // next_cursor de la página anterior
+}
+
+// OrderPage es una página de órdenes; NextCursor va vacío en la última
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	HasMore    bool    `json:"has_more"`
+	Limit      int     `json:"limit"`
+	SortBy     string  `json:"sort_by"`
+	SortDir    string  `json:"sort_dir"`
+}
+
+// Normalize completa los valores por defecto y valida los filtros
+func (q *OrderQuery) Normalize() error {
+	q.SortBy = strings.ToLower(strings.TrimSpace(q.SortBy))
+	if q.SortBy == "" {
+		q.SortBy = OrderSortCreatedAt
+	}
+	if q.SortBy != OrderSortCreatedAt && q.SortBy != OrderSortTotal {
+		return fmt.Errorf("sort_by inválido: %s (usa created_at o total)", q.SortBy)
+	}
+
+	q.SortDir = strings.ToLower(strings.TrimSpace(q.SortDir))
+	if q.SortDir == "" {
+		q.SortDir = "desc"
+	}
+	if q.SortDir != "asc" && q.SortDir != "desc" {
+		return fmt.Errorf("sort_dir inválido: %s (usa asc o desc)", q.SortDir)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultOrderPageSize
+	}
+	if q.Limit > MaxOrderPageSize {
+		q.Limit = MaxOrderPageSize
+	}
+
+	for i, status := range q.Statuses {
+		parsed, err := ParseOrderStatus(string(status))
+		if err != nil {
+			return err
+		}
+		q.Statuses[i] = parsed
+	}
+
+	if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+		return fmt.Errorf("created_from debe ser anterior a created_to")
+	}
+	if q.MinTotal != nil && q.MaxTotal != nil && *q.MinTotal > *q.MaxTotal {
+		return fmt.Errorf("min_total no puede ser mayor que max_total")
+	}
+	q.ProductID = strings.TrimSpace(q.ProductID)
+	return nil
+}
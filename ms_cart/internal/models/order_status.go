@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPendiente    OrderStatus = "PENDIENTE"
+	OrderStatusPagado       OrderStatus = "PAGADO"
+	OrderStatusRechazado    OrderStatus = "RECHAZADO"
+	OrderStatusStockFallido OrderStatus = "STOCK_FALLIDO"
+	OrderStatusCancelado    OrderStatus = "CANCELADO"
+	OrderStatusReembolsado  OrderStatus = "REEMBOLSADO"
+	OrderStatusEnviado      OrderStatus = "ENVIADO"
+	OrderStatusEntregado    OrderStatus = "ENTREGADO"
+	OrderStatusExpirado     OrderStatus = "EXPIRADO"
+	// OrderStatusReembolsoParcial admite nuevos reembolsos parciales hasta completar el total
+	OrderStatusReembolsoParcial OrderStatus = "REEMBOLSO_PARCIAL"
+)
+
+// orderTransitions define los cambios de estado permitidos; lo que no está aquí es ilegal
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPendiente: {OrderStatusPagado, OrderStatusRechazado, OrderStatusStockFallido, OrderStatusCancelado, OrderStatusExpirado},
+	// MP permite reintentar el pago sobre la misma preferencia después de un rechazo, hasta que la orden expira
+	OrderStatusRechazado: {OrderStatusPagado, OrderStatusCancelado, OrderStatusExpirado},
+	// El pago puede aprobarse pero fallar la confirmación del stock; cancelar una orden pagada implica reembolso
+	OrderStatusPagado:           {OrderStatusStockFallido, OrderStatusCancelado, OrderStatusReembolsado, OrderStatusReembolsoParcial, OrderStatusEnviado},
+	OrderStatusStockFallido:     {OrderStatusCancelado, OrderStatusReembolsado, OrderStatusReembolsoParcial},
+	// Con reembolso parcial (p. ej. una prenda sin stock) el resto de la orden todavía se despacha
+	OrderStatusReembolsoParcial: {OrderStatusReembolsoParcial, OrderStatusReembolsado, OrderStatusEnviado},
+	OrderStatusEnviado:          {OrderStatusEntregado},
+	// Las devoluciones aprobadas de una orden entregada se reembolsan total o parcialmente
+	OrderStatusEntregado:        {OrderStatusReembolsoParcial, OrderStatusReembolsado},
+	OrderStatusExpirado:         {},
+	OrderStatusCancelado:        {},
+	OrderStatusReembolsado:      {},
+}
+
+// ParseOrderStatus valida un estado recibido como texto
+func ParseOrderStatus(value string) (OrderStatus, error) {
+	status := OrderStatus(strings.ToUpper(strings.TrimSpace(value)))
+	if _, ok := orderTransitions[status]; !ok {
+		return "", fmt.Errorf("estado de orden desconocido: %s", value)
+	}
+	return status, nil
+}
+
+// userCancellable son los estados desde los que el cliente puede cancelar su propia orden
+var userCancellable = map[OrderStatus]bool{
+	OrderStatusPendiente:    true,
+	OrderStatusRechazado:    true,
+	OrderStatusPagado:       true,
+	OrderStatusStockFallido: true,
+}
+
+// CancellableByUser indica si el cliente puede cancelar la orden en este estado
+func (s OrderStatus) CancellableByUser() bool {
+	return userCancellable[s] && s.CanTransitionTo(OrderStatusCancelado)
+}
+
+// Shippable indica si se pueden crear despachos para la orden en este estado
+func (s OrderStatus) Shippable() bool {
+	return s == OrderStatusEnviado || s.CanTransitionTo(OrderStatusEnviado)
+}
+
+// Returnable indica si el cliente puede solicitar devoluciones sobre la orden
+func (s OrderStatus) Returnable() bool {
+	return s == OrderStatusEntregado || s == OrderStatusReembolsoParcial
+}
+
+// CanTransitionTo indica si el cambio de estado está permitido
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidStatusTransitionError se devuelve al intentar un cambio de estado no permitido
+type InvalidStatusTransitionError struct {
+	OrderID uint        `json:"order_id"`
+	From    OrderStatus `json:"from"`
+	To      OrderStatus `json:"to"`
+}
+
+func (e *InvalidStatusTransitionError) Error() string {
+	return fmt.Sprintf("transición de estado inválida para la orden #%d: %s -> %s", e.OrderID, e.From, e.To)
+}
+
+func (e *InvalidStatusTransitionError) Code() string {
+	return "INVALID_STATUS_TRANSITION"
+}
+
+func (e *InvalidStatusTransitionError) Details() interface{} {
+	return e
+}
+
+// StatusChangedError se devuelve cuando la orden cambió de estado entre la lectura y el cambio pedido
+type StatusChangedError struct {
+	OrderID  uint        `json:"order_id"`
+	Expected OrderStatus `json:"expected"`
+	Actual   OrderStatus `json:"actual"`
+}
+
+func (e *StatusChangedError) Error() string {
+	return fmt.Sprintf("la orden #%d cambió de estado (%s -> %s) mientras se procesaba; reintenta", e.OrderID, e.Expected, e.Actual)
+}
+
+func (e *StatusChangedError) Code() string {
+	return "ORDER_STATUS_CHANGED"
+}
+
+func (e *StatusChangedError) Details() interface{} {
+	return e
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// OrderStatusSource identifica quién o qué provocó un cambio de estado
+type OrderStatusSource string
+
+const (
+	StatusSourceWebhook        OrderStatusSource = "webhook"
+	StatusSourceReconciliation OrderStatusSource = "reconciliation"
+	StatusSourceAdmin          OrderStatusSource = "admin"
+	StatusSourceUser           OrderStatusSource = "user"
+	// StatusSourceSystem cubre los cambios automáticos del propio servicio (p. ej. fallo al reservar stock)
+	StatusSourceSystem OrderStatusSource = "system"
+)
+
+// StatusChange describe el contexto de un cambio de estado para el historial
+type StatusChange struct {
+	Source    OrderStatusSource
+	PaymentID string
+	Reason    string
+	// EventType, si no está vacío, encola ese evento de orden en el outbox dentro de la misma transacción
+	EventType string
+	// ExpectedStatus, si no está vacío, es el estado en que el llamador vio la orden al decidir el cambio:
+	// si la orden ya no está en él el cambio no se aplica (*StatusChangedError)
+	ExpectedStatus OrderStatus
+}
+
+// Check valida el cambio de la orden desde current a next
+func (c StatusChange) Check(orderID uint, current, next OrderStatus) error {
+	if c.ExpectedStatus != "" && current != c.ExpectedStatus {
+		return &StatusChangedError{OrderID: orderID, Expected: c.ExpectedStatus, Actual: current}
+	}
+	if !current.CanTransitionTo(next) {
+		return &InvalidStatusTransitionError{OrderID: orderID, From: current, To: next}
+	}
+	return nil
+}
+
+// OrderStatusHistory es una fila del historial; se escribe en la misma transacción que el cambio de estado
+type OrderStatusHistory struct {
+	ID         uint              `gorm:"primarykey" json:"id"`
+	OrderID    uint              `gorm:"index;not null" json:"order_id"`
+	FromStatus OrderStatus       `gorm:"type:varchar(30)" json:"from_status"`
+	ToStatus   OrderStatus       `gorm:"type:varchar(30);not null" json:"to_status"`
+	Source     OrderStatusSource `gorm:"type:varchar(20);not null" json:"source"`
+	PaymentID  string            `json:"payment_id,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	cases := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderStatusPendiente, OrderStatusPagado, true},
+		{OrderStatusPendiente, OrderStatusExpirado, true},
+		{OrderStatusRechazado, OrderStatusPagado, true},
+		{OrderStatusPagado, OrderStatusEnviado, true},
+		{OrderStatusPagado, OrderStatusEntregado, false},
+		{OrderStatusPagado, OrderStatusPendiente, false},
+		{OrderStatusReembolsoParcial, OrderStatusReembolsoParcial, true},
+		{OrderStatusEnviado, OrderStatusEntregado, true},
+		{OrderStatusEnviado, OrderStatusCancelado, false},
+		{OrderStatusEntregado, OrderStatusReembolsado, true},
+		{OrderStatusExpirado, OrderStatusPagado, false},
+		{OrderStatusCancelado, OrderStatusPagado, false},
+		{OrderStatusReembolsado, OrderStatusReembolsoParcial, false},
+		{OrderStatus("PENDING"), OrderStatusPagado, false},
+	}
+	for _, c := range cases {
+		if got := c.from.CanTransitionTo(c.to); got != c.want {
+			t.Errorf("%s -> %s: got %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestOrderStatusHelpers(t *testing.T) {
+	if !OrderStatusPagado.CancellableByUser() || OrderStatusEnviado.CancellableByUser() {
+		t.Error("solo las órdenes no despachadas se cancelan por el cliente")
+	}
+	if !OrderStatusReembolsoParcial.Shippable() || !OrderStatusEnviado.Shippable() || OrderStatusPendiente.Shippable() {
+		t.Error("Shippable no coincide con la tabla de transiciones")
+	}
+	if !OrderStatusEntregado.Returnable() || OrderStatusEnviado.Returnable() {
+		t.Error("solo se devuelven órdenes entregadas")
+	}
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	status, err := ParseOrderStatus(" pagado ")
+	if err != nil || status != OrderStatusPagado {
+		t.Fatalf("got %q, %v", status, err)
+	}
+	if _, err := ParseOrderStatus("PENDING"); err == nil {
+		t.Error("se esperaba error para un estado desconocido")
+	}
+}
+
+func TestInvalidStatusTransitionErrorIsCoded(t *testing.T) {
+	var err error = &InvalidStatusTransitionError{OrderID: 7, From: OrderStatusEnviado, To: OrderStatusCancelado}
+	var invalid *InvalidStatusTransitionError
+	if !errors.As(err, &invalid) || invalid.Code() != "INVALID_STATUS_TRANSITION" {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	OutboxPending = "PENDIENTE"
+	OutboxSent    = "ENVIADO"
+)
+
+// Tipos de evento de orden; se usan como routing key al publicar en order_events
+const (
+	OrderEventCreated   = "order.created"
+	OrderEventPaid      = "order.paid"
+	OrderEventCancelled = "order.cancelled"
+	OrderEventRefunded  = "order.refunded"
+	OrderEventShipped   = "order.shipped"
+	OrderEventDelivered = "order.delivered"
+	OrderEventExpired   = "order.expired"
+)
+
+// postgreSQL
+// OutboxEvent es un evento pendiente de publicar. Se inserta en la misma transacción que el cambio
+// de la orden y el relay lo publica después con confirmación del broker.
+type OutboxEvent struct {
+	ID            uint      `gorm:"primarykey"`
+	AggregateID   uint      `gorm:"index;not null"`
+	EventType     string    `gorm:"type:varchar(60);not null"`
+	Payload       string    `gorm:"type:jsonb;not null"`
+	Status        string    `gorm:"type:varchar(20);not null;default:'PENDIENTE';index:idx_outbox_pending,priority:1"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_pending,priority:2"`
+	Attempts      int       `gorm:"not null;default:0"`
+	LastError     string    `gorm:"type:text"`
+	SentAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// NewOrderOutboxEvent arma el evento con una foto de la orden al momento del cambio
+func NewOrderOutboxEvent(eventType string, order *Order) (*OutboxEvent, error) {
+	return newOutboxEvent(eventType, order.ID, map[string]interface{}{
+		"order_id": order.ID,
+		"user_id":  order.UserID,
+		"status":   order.Status,
+		"total":    order.Total,
+		"items":    order.OrderItems,
+	})
+}
+
+// NewShipmentOutboxEvent arma el evento de un despacho de la orden
+func NewShipmentOutboxEvent(eventType string, order *Order, shipment *Shipment) (*OutboxEvent, error) {
+	return newOutboxEvent(eventType, order.ID, map[string]interface{}{
+		"order_id":        order.ID,
+		"user_id":         order.UserID,
+		"shipment_id":     shipment.ID,
+		"carrier":         shipment.Carrier,
+		"tracking_number": shipment.TrackingNumber,
+		"shipped_at":      shipment.ShippedAt,
+		"items":           shipment.Items,
+	})
+}
+
+func newOutboxEvent(eventType string, orderID uint, fields map[string]interface{}) (*OutboxEvent, error) {
+	now := time.Now()
+	fields["event"] = eventType
+	fields["timestamp"] = now
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("error al serializar evento %s de la orden #%d: %w", eventType, orderID, err)
+	}
+
+	return &OutboxEvent{
+		AggregateID:   orderID,
+		EventType:     eventType,
+		Payload:       string(body),
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+	}, nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// postgreSQL
+// ProductPurchaseLimit limita cuántas unidades de un producto (ej: edición limitada) puede comprar cada usuario
+type ProductPurchaseLimit struct {
+	gorm.Model
+
+	ProductID  string `gorm:"uniqueIndex;not null" json:"product_id"`
+	MaxPerUser int    `gorm:"not null" json:"max_per_user"`
+}
+
+// PurchaseLimitOrderStatuses son los estados de las órdenes cuyas unidades cuentan para el límite por
+// usuario: las que esperan pago y las pagadas (enviadas o entregadas) que no se reembolsaron completas
+var PurchaseLimitOrderStatuses = []OrderStatus{
+	OrderStatusPendiente,
+	OrderStatusPagado,
+	OrderStatusEnviado,
+	OrderStatusEntregado,
+	OrderStatusReembolsoParcial,
+}
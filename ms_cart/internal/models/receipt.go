@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// postgreSQL
+// Receipt es el comprobante emitido para una orden pagada. Se guarda renderizado para que
+// cada descarga entregue exactamente el mismo documento.
+type Receipt struct {
+	gorm.Model
+
+	OrderID  uint      `gorm:"uniqueIndex;not null"`
+	Number   int64     `gorm:"uniqueIndex;not null"` // folio correlativo sin saltos
+	IssuedAt time.Time `gorm:"not null"`
+	HTML     string    `gorm:"type:text;not null"`
+	PDF      []byte    `gorm:"type:bytea;not null"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// postgreSQL
+// Refund registra cada reembolso emitido en Mercado Pago para una orden (una orden puede tener varios parciales)
+type Refund struct {
+	gorm.Model
+
+	OrderID    uint              `gorm:"not null;index" json:"order_id"`
+	PaymentID  string            `gorm:"type:varchar(50);not null" json:"payment_id"`
+	MPRefundID string            `gorm:"type:varchar(50)" json:"mp_refund_id"`
+	Amount     int64             `gorm:"type:numeric;not null" json:"amount"`
+	Status     string            `gorm:"type:varchar(30)" json:"status"` // estado informado por MP (approved, in_process...)
+	Source     OrderStatusSource `gorm:"type:varchar(20);not null" json:"source"`
+	Reason     string            `gorm:"type:text" json:"reason"`
+	// IdempotencyKey es la clave enviada a MP; evita registrar dos veces el mismo reembolso
+	IdempotencyKey string `gorm:"type:varchar(100);index" json:"-"`
+}
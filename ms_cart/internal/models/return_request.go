@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReturnRequested = "SOLICITADA"
+	ReturnApproved  = "APROBADA"
+	ReturnRejected  = "RECHAZADA"
+	ReturnReceived  = "RECIBIDA"
+	ReturnCompleted = "COMPLETADA"
+
+	ReturnResolutionRefund   = "REEMBOLSO"
+	ReturnResolutionExchange = "CAMBIO"
+)
+
+// Eventos de devolución; se publican por el outbox en order_events con el ID de la orden como agregado
+const (
+	ReturnEventRequested = "return.requested"
+	ReturnEventApproved  = "return.approved"
+	ReturnEventRejected  = "return.rejected"
+	ReturnEventReceived  = "return.received"
+	ReturnEventCompleted = "return.completed"
+)
+
+var returnTransitions = map[string][]string{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnCompleted},
+}
+
+// ReturnCanTransition indica si la devolución puede pasar de from a to
+func ReturnCanTransition(from, to string) bool {
+	for _, allowed := range returnTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// postgreSQL
+// ReturnRequest es una solicitud de devolución (RMA) sobre items de una orden entregada
+type ReturnRequest struct {
+	gorm.Model
+
+	OrderID         uint         `gorm:"not null;index" json:"order_id"`
+	UserID          uint         `gorm:"not null;index" json:"user_id"`
+	Status          string       `gorm:"type:varchar(20);not null;index" json:"status"`
+	Resolution      string       `gorm:"type:varchar(20);not null" json:"resolution"`
+	Reason          string       `gorm:"type:text" json:"reason"`
+	AdminNote       string       `gorm:"type:text" json:"admin_note,omitempty"`
+	RefundID        *uint        `json:"refund_id,omitempty"`
+	ExchangeOrderID *uint        `json:"exchange_order_id,omitempty"`
+	ApprovedAt      *time.Time   `json:"approved_at,omitempty"`
+	ReceivedAt      *time.Time   `json:"received_at,omitempty"`
+	CompletedAt     *time.Time   `json:"completed_at,omitempty"`
+	Items           []ReturnItem `gorm:"foreignKey:ReturnRequestID" json:"items"`
+}
+
+// postgreSQL
+// ReturnItem son las unidades devueltas de una línea; en un cambio lleva la variante nueva
+type ReturnItem struct {
+	ID              uint   `gorm:"primarykey" json:"id"`
+	ReturnRequestID uint   `gorm:"not null;index" json:"return_request_id"`
+	OrderItemID     uint   `gorm:"not null;index" json:"order_item_id"`
+	Quantity        int    `gorm:"not null" json:"quantity"`
+	Reason          string `gorm:"type:text" json:"reason,omitempty"`
+	ExchangeSize    string `gorm:"type:varchar(20)" json:"exchange_size,omitempty"`
+	ExchangeColor   string `gorm:"type:varchar(40)" json:"exchange_color,omitempty"`
+}
+
+// ValidateReturnItems revisa que las unidades pedidas pertenezcan a la orden y no superen lo que queda por
+// devolver, descontando las devoluciones existentes que no fueron rechazadas
+func ValidateReturnItems(order *Order, existing []ReturnRequest, resolution string, requested []ReturnItem) error {
+	if len(requested) == 0 {
+		return fmt.Errorf("la devolución debe incluir al menos un item")
+	}
+
+	returnable := make(map[uint]int, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		returnable[item.ID] = item.Quantity
+	}
+	for _, ret := range existing {
+		if ret.Status == ReturnRejected {
+			continue
+		}
+		for _, item := range ret.Items {
+			returnable[item.OrderItemID] -= item.Quantity
+		}
+	}
+
+	for i := range requested {
+		item := &requested[i]
+		remaining, ok := returnable[item.OrderItemID]
+		if !ok {
+			return fmt.Errorf("el item %d no pertenece a la orden #%d", item.OrderItemID, order.ID)
+		}
+		if item.Quantity <= 0 || item.Quantity > remaining {
+			return fmt.Errorf("cantidad inválida para el item %d: quedan %d unidades por devolver", item.OrderItemID, remaining)
+		}
+		returnable[item.OrderItemID] -= item.Quantity
+
+		item.ExchangeSize = strings.ToUpper(strings.TrimSpace(item.ExchangeSize))
+		item.ExchangeColor = strings.ToLower(strings.TrimSpace(item.ExchangeColor))
+		if resolution == ReturnResolutionExchange && item.ExchangeSize == "" && item.ExchangeColor == "" {
+			return fmt.Errorf("el cambio del item %d necesita la talla o el color nuevos", item.OrderItemID)
+		}
+	}
+	return nil
+}
+
+// NewReturnOutboxEvent arma el evento de un paso de la devolución
+func NewReturnOutboxEvent(eventType string, ret *ReturnRequest) (*OutboxEvent, error) {
+	return newOutboxEvent(eventType, ret.OrderID, map[string]interface{}{
+		"order_id":          ret.OrderID,
+		"user_id":           ret.UserID,
+		"return_id":         ret.ID,
+		"status":            ret.Status,
+		"resolution":        ret.Resolution,
+		"refund_id":         ret.RefundID,
+		"exchange_order_id": ret.ExchangeOrderID,
+		"items":             ret.Items,
+	})
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ShipmentPreparing = "PREPARANDO"
+	ShipmentShipped   = "ENVIADO"
+	ShipmentDelivered = "ENTREGADO"
+)
+
+// postgreSQL
+// Shipment es un despacho de la orden; una orden puede dividirse en varios
+type Shipment struct {
+	gorm.Model
+
+	OrderID        uint           `gorm:"not null;index" json:"order_id"`
+	Carrier        string         `gorm:"type:varchar(60)" json:"carrier"`
+	TrackingNumber string         `gorm:"type:varchar(100);index" json:"tracking_number"`
+	Status         string         `gorm:"type:varchar(20);not null;default:'PREPARANDO'" json:"status"`
+	ShippedAt      *time.Time     `json:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+	Items          []ShipmentItem `gorm:"foreignKey:ShipmentID" json:"items"`
+}
+
+// postgreSQL
+// ShipmentItem indica cuántas unidades de una línea de la orden van en el despacho
+type ShipmentItem struct {
+	ID          uint `gorm:"primarykey" json:"id"`
+	ShipmentID  uint `gorm:"not null;index" json:"shipment_id"`
+	OrderItemID uint `gorm:"not null;index" json:"order_item_id"`
+	Quantity    int  `gorm:"not null" json:"quantity"`
+}
+
+// AllocateShipmentItems valida las unidades pedidas para un nuevo despacho contra lo que falta por despachar.
+// Sin items pedidos, el despacho lleva todo lo pendiente.
+func AllocateShipmentItems(order *Order, existing []Shipment, requested []ShipmentItem) ([]ShipmentItem, error) {
+	if !order.Status.Shippable() {
+		return nil, &InvalidStatusTransitionError{OrderID: order.ID, From: order.Status, To: OrderStatusEnviado}
+	}
+	pending := pendingShipmentUnits(order, existing)
+
+	if len(requested) == 0 {
+		for _, item := range order.OrderItems {
+			if pending[item.ID] > 0 {
+				requested = append(requested, ShipmentItem{OrderItemID: item.ID, Quantity: pending[item.ID]})
+			}
+		}
+		if len(requested) == 0 {
+			return nil, fmt.Errorf("la orden #%d no tiene unidades pendientes de despacho", order.ID)
+		}
+		return requested, nil
+	}
+
+	for _, item := range requested {
+		remaining, ok := pending[item.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("el item %d no pertenece a la orden #%d", item.OrderItemID, order.ID)
+		}
+		if item.Quantity <= 0 || item.Quantity > remaining {
+			return nil, fmt.Errorf("cantidad inválida para el item %d: quedan %d unidades por despachar", item.OrderItemID, remaining)
+		}
+		pending[item.OrderItemID] -= item.Quantity
+	}
+	return requested, nil
+}
+
+// OrderStatusForShipments calcula el estado de envío de la orden: ENTREGADO cuando todas las unidades
+// fueron despachadas y todos los despachos entregados, ENVIADO si alguno salió, o "" si ninguno.
+func OrderStatusForShipments(order *Order, shipments []Shipment) OrderStatus {
+	anyShipped := false
+	allDelivered := len(shipments) > 0
+	for _, shipment := range shipments {
+		switch shipment.Status {
+		case ShipmentShipped:
+			anyShipped = true
+			allDelivered = false
+		case ShipmentDelivered:
+			anyShipped = true
+		default:
+			allDelivered = false
+		}
+	}
+
+	if allDelivered {
+		for _, units := range pendingShipmentUnits(order, shipments) {
+			if units > 0 {
+				allDelivered = false
+				break
+			}
+		}
+	}
+
+	switch {
+	case allDelivered:
+		return OrderStatusEntregado
+	case anyShipped:
+		return OrderStatusEnviado
+	default:
+		return ""
+	}
+}
+
+// pendingShipmentUnits devuelve, por ID de item, las unidades que aún no están en ningún despacho
+func pendingShipmentUnits(order *Order, shipments []Shipment) map[uint]int {
+	pending := make(map[uint]int, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		pending[item.ID] = item.Quantity
+	}
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			pending[item.OrderItemID] -= item.Quantity
+		}
+	}
+	return pending
+}
@@ -0,0 +1,82 @@
+package models
+
+import "testing"
+
+func shipmentTestOrder(status OrderStatus) *Order {
+	order := &Order{Status: status, OrderItems: []OrderItem{{Quantity: 2}, {Quantity: 1}}}
+	order.ID = 1
+	order.OrderItems[0].ID = 10
+	order.OrderItems[1].ID = 11
+	return order
+}
+
+func shipment(status string, items ...ShipmentItem) Shipment {
+	return Shipment{Status: status, Items: items}
+}
+
+func TestOrderStatusForShipments(t *testing.T) {
+	order := shipmentTestOrder(OrderStatusPagado)
+	all := []ShipmentItem{{OrderItemID: 10, Quantity: 2}, {OrderItemID: 11, Quantity: 1}}
+
+	cases := []struct {
+		name      string
+		shipments []Shipment
+		want      OrderStatus
+	}{
+		{"sin despachos", nil, ""},
+		{"en preparación", []Shipment{shipment(ShipmentPreparing, all...)}, ""},
+		{"uno enviado", []Shipment{shipment(ShipmentShipped, all...)}, OrderStatusEnviado},
+		{"todo entregado", []Shipment{shipment(ShipmentDelivered, all...)}, OrderStatusEntregado},
+		{
+			"entregado pero faltan unidades por despachar",
+			[]Shipment{shipment(ShipmentDelivered, ShipmentItem{OrderItemID: 10, Quantity: 2})},
+			OrderStatusEnviado,
+		},
+		{
+			"un despacho entregado y otro en camino",
+			[]Shipment{
+				shipment(ShipmentDelivered, ShipmentItem{OrderItemID: 10, Quantity: 2}),
+				shipment(ShipmentShipped, ShipmentItem{OrderItemID: 11, Quantity: 1}),
+			},
+			OrderStatusEnviado,
+		},
+		{
+			"un despacho entregado y otro en preparación",
+			[]Shipment{
+				shipment(ShipmentDelivered, ShipmentItem{OrderItemID: 10, Quantity: 2}),
+				shipment(ShipmentPreparing, ShipmentItem{OrderItemID: 11, Quantity: 1}),
+			},
+			OrderStatusEnviado,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := OrderStatusForShipments(order, c.shipments); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAllocateShipmentItems(t *testing.T) {
+	order := shipmentTestOrder(OrderStatusPagado)
+	existing := []Shipment{shipment(ShipmentShipped, ShipmentItem{OrderItemID: 10, Quantity: 1})}
+
+	items, err := AllocateShipmentItems(order, existing, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Quantity != 1 || items[1].Quantity != 1 {
+		t.Fatalf("sin items pedidos debe llevar todo lo pendiente, got %+v", items)
+	}
+
+	if _, err := AllocateShipmentItems(order, existing, []ShipmentItem{{OrderItemID: 10, Quantity: 2}}); err == nil {
+		t.Error("se esperaba error al despachar más unidades de las pendientes")
+	}
+	if _, err := AllocateShipmentItems(order, existing, []ShipmentItem{{OrderItemID: 99, Quantity: 1}}); err == nil {
+		t.Error("se esperaba error para un item ajeno a la orden")
+	}
+	if _, err := AllocateShipmentItems(shipmentTestOrder(OrderStatusPendiente), nil, nil); err == nil {
+		t.Error("una orden sin pagar no se despacha")
+	}
+}
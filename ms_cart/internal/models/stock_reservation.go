@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReservationActive    = "ACTIVA"
+	ReservationReleased  = "LIBERADA"
+	ReservationCommitted = "CONFIRMADA"
+)
+
+// postgreSQL
+// StockReservation registra el stock descontado en ms_products mientras la orden espera el pago
+type StockReservation struct {
+	gorm.Model
+
+	OrderID     uint      `gorm:"uniqueIndex;not null"`
+	Status      string    `gorm:"not null;index"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	ReleasedAt  *time.Time
+	CommittedAt *time.Time
+	Reason      string `gorm:"type:text"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// TaxBreakdown separa un monto con IVA incluido en neto e impuesto
+type TaxBreakdown struct {
+	RatePercent int64 `json:"rate_percent"`
+	Net         int64 `json:"net"`
+	Tax         int64 `json:"tax"`
+	Gross       int64 `json:"gross"`
+}
@@ -0,0 +1,69 @@
+package payments
+
+// applyDiscount reparte el descuento entre los items de forma proporcional a su subtotal.
+// Mercado Pago no acepta precios negativos y CLP no tiene decimales, así que cuando el
+// subtotal rebajado de una línea no es divisible por su cantidad se separa la unidad sobrante
+// en su propio item para que la suma cobrada sea exactamente subtotal - descuento.
+func applyDiscount(items []MPItem, discount int64) []MPItem {
+	var subtotal int64
+	for _, item := range items {
+		subtotal += item.UnitPrice * int64(item.Quantity)
+	}
+	if subtotal == 0 || discount <= 0 {
+		return items
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+
+	shares := make([]int64, len(items))
+	var assigned int64
+	for i, item := range items {
+		shares[i] = discount * item.UnitPrice * int64(item.Quantity) / subtotal
+		assigned += shares[i]
+	}
+	// El resto del redondeo se asigna a la primera línea que aún pueda absorberlo
+	for i, item := range items {
+		if assigned == discount {
+			break
+		}
+		room := item.UnitPrice*int64(item.Quantity) - shares[i]
+		extra := discount - assigned
+		if extra > room {
+			extra = room
+		}
+		shares[i] += extra
+		assigned += extra
+	}
+
+	result := make([]MPItem, 0, len(items))
+	for i, item := range items {
+		lineTotal := item.UnitPrice*int64(item.Quantity) - shares[i]
+		if lineTotal <= 0 {
+			continue
+		}
+
+		qty := int64(item.Quantity)
+		unit := lineTotal / qty
+		remainder := lineTotal % qty
+
+		if remainder == 0 {
+			item.UnitPrice = unit
+			result = append(result, item)
+			continue
+		}
+
+		// qty-1 unidades a precio base y una unidad que absorbe el resto
+		if qty > 1 && unit > 0 {
+			base := item
+			base.Quantity = int(qty - 1)
+			base.UnitPrice = unit
+			result = append(result, base)
+		}
+		last := item
+		last.Quantity = 1
+		last.UnitPrice = unit + remainder
+		result = append(result, last)
+	}
+	return result
+}
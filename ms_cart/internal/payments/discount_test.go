@@ -0,0 +1,85 @@
+package payments
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyDiscount(t *testing.T) {
+	cases := []struct {
+		name     string
+		items    []MPItem
+		discount int64
+		want     []MPItem
+	}{
+		{
+			name:     "sin descuento",
+			items:    []MPItem{{Title: "A", Quantity: 2, UnitPrice: 1000}},
+			discount: 0,
+			want:     []MPItem{{Title: "A", Quantity: 2, UnitPrice: 1000}},
+		},
+		{
+			name:     "10% divisible",
+			items:    []MPItem{{Title: "A", Quantity: 1, UnitPrice: 10000}, {Title: "B", Quantity: 2, UnitPrice: 5000}},
+			discount: 2000,
+			want:     []MPItem{{Title: "A", Quantity: 1, UnitPrice: 9000}, {Title: "B", Quantity: 2, UnitPrice: 4500}},
+		},
+		{
+			// 15% de 9990 = 1498: las 8492 restantes no se dividen en 3 unidades
+			name:     "15% con resto por unidad",
+			items:    []MPItem{{Title: "A", Quantity: 3, UnitPrice: 3330}},
+			discount: 1498,
+			want:     []MPItem{{Title: "A", Quantity: 2, UnitPrice: 2830}, {Title: "A", Quantity: 1, UnitPrice: 2832}},
+		},
+		{
+			// 10% de 3000 repartido en tercios: el peso que sobra del redondeo va a la primera línea
+			name:     "resto del reparto proporcional",
+			items:    []MPItem{{Title: "A", Quantity: 1, UnitPrice: 1000}, {Title: "B", Quantity: 1, UnitPrice: 1000}, {Title: "C", Quantity: 1, UnitPrice: 1000}},
+			discount: 100,
+			want:     []MPItem{{Title: "A", Quantity: 1, UnitPrice: 966}, {Title: "B", Quantity: 1, UnitPrice: 967}, {Title: "C", Quantity: 1, UnitPrice: 967}},
+		},
+		{
+			name:     "monto mayor que una línea",
+			items:    []MPItem{{Title: "A", Quantity: 1, UnitPrice: 100}, {Title: "B", Quantity: 1, UnitPrice: 10000}},
+			discount: 10000,
+			want:     []MPItem{{Title: "B", Quantity: 1, UnitPrice: 100}},
+		},
+		{
+			name:     "monto mayor que el total",
+			items:    []MPItem{{Title: "A", Quantity: 2, UnitPrice: 1000}},
+			discount: 5000,
+			want:     []MPItem{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			items := append([]MPItem{}, c.items...)
+			got := applyDiscount(items, c.discount)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %+v, want %+v", got, c.want)
+			}
+
+			var subtotal, charged int64
+			for _, item := range c.items {
+				subtotal += item.UnitPrice * int64(item.Quantity)
+			}
+			for _, item := range got {
+				if item.UnitPrice <= 0 || item.Quantity <= 0 {
+					t.Errorf("item inválido para Mercado Pago: %+v", item)
+				}
+				charged += item.UnitPrice * int64(item.Quantity)
+			}
+			discount := c.discount
+			if discount > subtotal {
+				discount = subtotal
+			}
+			if discount < 0 {
+				discount = 0
+			}
+			if charged != subtotal-discount {
+				t.Errorf("cobrado %d, want %d", charged, subtotal-discount)
+			}
+		})
+	}
+}
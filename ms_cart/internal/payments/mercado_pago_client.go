@@ -13,11 +13,23 @@ import (
 type PaymentStatusDetails struct {
     Status            string
     ExternalReference string
+    // Amount es el monto cobrado (transaction_amount), en pesos enteros
+    Amount            int64
 }
 
 type PaymentClient interface {
-    StartTransaction(ctx context.Context, orderID uint, total int64, items []models.OrderItem) (string, error)
+    // StartTransaction crea la preferencia de pago; el monto cobrado coincide con order.Total
+    StartTransaction(ctx context.Context, order *models.Order) (string, error)
     GetPaymentStatus(ctx context.Context, paymentID string) (*PaymentStatusDetails, error)
+    // RefundPayment reembolsa amount del pago, o el total si amount es 0; idempotencyKey evita
+    // reembolsos duplicados si se reintenta
+    RefundPayment(ctx context.Context, paymentID string, amount int64, idempotencyKey string) (*RefundDetails, error)
+}
+
+type RefundDetails struct {
+    ID     string
+    Status string
+    Amount int64
 }
 
 type MercadoPagoClient struct {
@@ -53,33 +65,69 @@ type MPPreferenceRequest struct {
     BackURLs          *MPBackURLs `json:"back_urls,omitempty"`
     NotificationURL   string      `json:"notification_url,omitempty"`
     AutoReturn        string      `json:"auto_return,omitempty"`
+    Expires           bool        `json:"expires,omitempty"`
+    ExpirationDateFrom string     `json:"expiration_date_from,omitempty"`
+    ExpirationDateTo  string      `json:"expiration_date_to,omitempty"`
+    // DateOfExpiration limita los medios offline (cupones de pago en efectivo)
+    DateOfExpiration  string      `json:"date_of_expiration,omitempty"`
 }
 
+// mpDateFormat es el formato ISO 8601 con milisegundos y zona horaria que exige MP
+const mpDateFormat = "2006-01-02T15:04:05.000-07:00"
+
 type MPPreferenceResponse struct {
     ID         string `json:"id"`
     InitPoint  string `json:"init_point"`
 }
 
+type MPRefundRequest struct {
+    Amount int64 `json:"amount,omitempty"`
+}
+
+type MPRefundResponse struct {
+    ID     json.Number `json:"id"`
+    Status string      `json:"status"`
+    Amount float64     `json:"amount"`
+}
+
 type MPPaymentResponse struct {
-    Status            string `json:"status"`
-    ExternalReference string `json:"external_reference"`
+    Status            string  `json:"status"`
+    ExternalReference string  `json:"external_reference"`
+    TransactionAmount float64 `json:"transaction_amount"`
 }
 
-func (m *MercadoPagoClient) StartTransaction(ctx context.Context, orderID uint, total int64, items []models.OrderItem) (string, error) {
+func (m *MercadoPagoClient) StartTransaction(ctx context.Context, order *models.Order) (string, error) {
 
-	mpItems := make([]MPItem, 0, len(items))
-    for _, item := range items {
+	mpItems := make([]MPItem, 0, len(order.OrderItems))
+    for _, item := range order.OrderItems {
 
         mpItems = append(mpItems, MPItem{
-            Title:     item.NameSnapshot,
+            Title:     item.DisplayName(),
             Quantity:  item.Quantity,
             UnitPrice: int64(item.UnitPrice),
         })
     }
 
+    if order.DiscountTotal > 0 {
+        mpItems = applyDiscount(mpItems, order.DiscountTotal)
+    }
+
+    // El envío va como línea propia y no participa del descuento
+    if order.ShippingCost > 0 {
+        title := "Envío"
+        if order.ShippingRateName != "" {
+            title = order.ShippingRateName
+        }
+        mpItems = append(mpItems, MPItem{
+            Title:     title,
+            Quantity:  1,
+            UnitPrice: order.ShippingCost,
+        })
+    }
+
     request := MPPreferenceRequest{
         Items:             mpItems,
-        ExternalReference: fmt.Sprintf("%d", orderID),
+        ExternalReference: fmt.Sprintf("%d", order.ID),
     }
     
     if frontendURL := os.Getenv("FRONTEND_URL"); frontendURL != "" {
@@ -95,6 +143,14 @@ func (m *MercadoPagoClient) StartTransaction(ctx context.Context, orderID uint,
     
     request.AutoReturn = "approved"
 
+    // La preferencia vence junto con la orden para que no entre un pago sobre una orden expirada
+    if order.ExpiresAt != nil {
+        request.Expires = true
+        request.ExpirationDateFrom = order.CreatedAt.Format(mpDateFormat)
+        request.ExpirationDateTo = order.ExpiresAt.Format(mpDateFormat)
+        request.DateOfExpiration = order.ExpiresAt.Format(mpDateFormat)
+    }
+
     jsonData, err := json.Marshal(request)
     if err != nil {
         return "", fmt.Errorf("error al serializar la preferencia: %w", err)
@@ -165,7 +221,53 @@ func (m *MercadoPagoClient) GetPaymentStatus(ctx context.Context, paymentID stri
     details := &PaymentStatusDetails{
         Status:            payment.Status, 
         ExternalReference: payment.ExternalReference,
+        Amount:            int64(payment.TransactionAmount),
     }
 
     return details, nil
 }
+
+func (m *MercadoPagoClient) RefundPayment(ctx context.Context, paymentID string, amount int64, idempotencyKey string) (*RefundDetails, error) {
+
+    // Sin amount MP reembolsa el total disponible del pago
+    jsonData, err := json.Marshal(MPRefundRequest{Amount: amount})
+    if err != nil {
+        return nil, fmt.Errorf("error al serializar el reembolso: %w", err)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "POST", m.baseURL+"/v1/payments/"+paymentID+"/refunds", bytes.NewBuffer(jsonData))
+    if err != nil {
+        return nil, fmt.Errorf("error al crear la petición HTTP: %w", err)
+    }
+
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer "+m.accessToken)
+    req.Header.Set("X-Idempotency-Key", idempotencyKey)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("error al hacer la petición a Mercado Pago: %w", err)
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return nil, fmt.Errorf("error al leer la respuesta: %w", err)
+    }
+
+    if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("error en Mercado Pago al reembolsar (status %d): %s", resp.StatusCode, string(body))
+    }
+
+    var refund MPRefundResponse
+    if err := json.Unmarshal(body, &refund); err != nil {
+        return nil, fmt.Errorf("error al deserializar la respuesta: %w", err)
+    }
+
+    return &RefundDetails{
+        ID:     refund.ID.String(),
+        Status: refund.Status,
+        Amount: int64(refund.Amount),
+    }, nil
+}
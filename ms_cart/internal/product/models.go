@@ -1,30 +1,130 @@
 package product
 
+import "github.com/C0kke/FitFashion/ms_cart/internal/models"
+
 type ProductInput struct {
     ProductID string `json:"productId"`
+    Size      string `json:"size,omitempty"`
+    Color     string `json:"color,omitempty"`
     Quantity  int    `json:"quantity"`
+    // BundleID y CapturedPrice son metadata interna de ms_cart, no se envían a ms_products
+    BundleID      string `json:"-"`
+    CapturedPrice int    `json:"-"`
 }
 
 type StockValidationOutput struct {
     Valid   bool   `json:"valid"`
     Message string `json:"message"`
+    // Items trae el detalle por línea en el mismo orden de la petición
+    Items   []StockLineResult `json:"items"`
+}
+
+type StockLineResult struct {
+    ProductID string `json:"productId"`
+    Requested int    `json:"requested"`
+    Available int    `json:"available"`
+    Found     bool   `json:"found"`
+    Valid     bool   `json:"valid"`
 }
 
 type CartItemSnapshot struct {
     ProductID    string  `json:"productId"`
     NameSnapshot string `json:"nameSnapshot"`
+    Size         string `json:"size,omitempty"`
+    Color        string `json:"color,omitempty"`
+    BundleID     string `json:"bundleId,omitempty"`
     UnitPrice    int     `json:"unitPrice"`
     Quantity     int    `json:"quantity"`
     Subtotal     int    `json:"subtotal"`
+
+    // AddedUnitPrice es el precio al momento de agregar; PriceChanged indica si difiere del actual
+    AddedUnitPrice int  `json:"addedUnitPrice,omitempty"`
+    PriceChanged   bool `json:"priceChanged"`
+
+    NetSubtotal int `json:"netSubtotal"`
+    TaxAmount   int `json:"taxAmount"`
+}
+
+func (i CartItemSnapshot) LineKey() string {
+    return models.CartItem{
+        ProductID: i.ProductID,
+        Variant:   models.Variant{Size: i.Size, Color: i.Color},
+        BundleID:  i.BundleID,
+    }.LineKey()
 }
 
 type CartCalculationOutput struct {
     UserID     string             `json:"user_id"`
     TotalPrice int                `json:"totalPrice"`
     Items      []CartItemSnapshot `json:"items"`
+
+    // Campos calculados por ms_cart (ms_products no conoce los cupones)
+    Subtotal    int                   `json:"subtotal"`
+    Discounts   []models.DiscountLine `json:"discounts"`
+    Bundles     []BundleSummary       `json:"bundles"`
+    CouponCode  string                `json:"couponCode,omitempty"`
+    CouponError string                `json:"couponError,omitempty"`
+    PriceChanged bool                 `json:"priceChanged"`
+    Tax         *models.TaxBreakdown  `json:"tax,omitempty"`
+}
+
+// PriceChanges lista las líneas cuyo precio actual difiere del capturado al agregarlas
+func (o *CartCalculationOutput) PriceChanges() []models.PriceChange {
+    changes := []models.PriceChange{}
+    for _, item := range o.Items {
+        if !item.PriceChanged {
+            continue
+        }
+        changes = append(changes, models.PriceChange{
+            ProductID: item.ProductID,
+            Variant:   models.Variant{Size: item.Size, Color: item.Color},
+            BundleID:  item.BundleID,
+            OldPrice:  item.AddedUnitPrice,
+            NewPrice:  item.UnitPrice,
+        })
+    }
+    return changes
+}
+
+// BundleSummary agrupa las prendas de un outfit agregado como una sola línea
+type BundleSummary struct {
+    BundleID   string   `json:"bundleId"`
+    ProductIDs []string `json:"productIds"`
+    Quantity   int      `json:"quantity"`
+    UnitPrice  int      `json:"unitPrice"`
+    Subtotal   int      `json:"subtotal"`
+}
+
+type OutfitCalculationOutput struct {
+    TotalPrice int `json:"totalPrice"`
 }
 
 type DecreaseStockOutput struct {
     Success bool   `json:"success"`
     Message string `json:"message"`
-}
\ No newline at end of file
+}
+
+// AttachLineDetails copia talla, color y outfit de los inputs a los snapshots. ms_products responde
+// en el mismo orden de entrada (omitiendo productos inexistentes), así que se asignan en orden por producto.
+func (o *CartCalculationOutput) AttachLineDetails(inputs []ProductInput) {
+    pending := make(map[string][]ProductInput)
+    for _, in := range inputs {
+        pending[in.ProductID] = append(pending[in.ProductID], in)
+    }
+
+    for i := range o.Items {
+        queue := pending[o.Items[i].ProductID]
+        if len(queue) == 0 {
+            continue
+        }
+        o.Items[i].Size = queue[0].Size
+        o.Items[i].Color = queue[0].Color
+        o.Items[i].BundleID = queue[0].BundleID
+        o.Items[i].AddedUnitPrice = queue[0].CapturedPrice
+        o.Items[i].PriceChanged = queue[0].CapturedPrice != 0 && queue[0].CapturedPrice != o.Items[i].UnitPrice
+        if o.Items[i].PriceChanged {
+            o.PriceChanged = true
+        }
+        pending[o.Items[i].ProductID] = queue[1:]
+    }
+}
@@ -20,6 +20,8 @@ type ClientInterface interface {
 	ValidateStock(ctx context.Context, items []ProductInput) (*StockValidationOutput, error)
 	CalculateCart(ctx context.Context, items []ProductInput) (*CartCalculationOutput, error)
 	DecreaseStock(ctx context.Context, items []ProductInput) (*DecreaseStockOutput, error)
+	IncreaseStock(ctx context.Context, items []ProductInput) (*DecreaseStockOutput, error)
+	CalculateOutfitPrice(ctx context.Context, productIDs []string) (*OutfitCalculationOutput, error)
 }
 
 const (
@@ -146,3 +148,21 @@ func (c *ProductClient) DecreaseStock(ctx context.Context, items []ProductInput)
 	}
 	return &output, nil
 }
+
+func (c *ProductClient) CalculateOutfitPrice(ctx context.Context, productIDs []string) (*OutfitCalculationOutput, error) {
+	var output OutfitCalculationOutput
+	err := c.CallRPC(ctx, "calculate_outfit_price", productIDs, &output)
+	if err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+func (c *ProductClient) IncreaseStock(ctx context.Context, items []ProductInput) (*DecreaseStockOutput, error) {
+	var output DecreaseStockOutput
+	err := c.CallRPC(ctx, "increase_stock", items, &output)
+	if err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
@@ -0,0 +1,96 @@
+package receipts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+// Document es el contenido del comprobante, tomado del snapshot de la orden al emitirlo
+type Document struct {
+	Issuer          string
+	Number          int64
+	IssuedAt        time.Time
+	OrderID         uint
+	PaymentID       string
+	ShippingAddress string
+	Lines           []Line
+	Subtotal        int64
+	Discount        int64
+	CouponCode      string
+	ShippingCost    int64
+	ShippingName    string
+	TaxRatePercent  int64
+	Net             int64
+	Tax             int64
+	Total           int64
+}
+
+type Line struct {
+	Description string
+	Quantity    int
+	UnitPrice   int64
+	Amount      int64
+}
+
+func NewDocument(issuer string, number int64, issuedAt time.Time, order *models.Order) Document {
+	doc := Document{
+		Issuer:          issuer,
+		Number:          number,
+		IssuedAt:        issuedAt,
+		OrderID:         order.ID,
+		PaymentID:       order.PaymentID,
+		ShippingAddress: order.ShippingDetails.String(),
+		Subtotal:        order.Subtotal,
+		Discount:        order.DiscountTotal,
+		CouponCode:      order.CouponCode,
+		ShippingCost:    order.ShippingCost,
+		ShippingName:    order.ShippingRateName,
+		TaxRatePercent:  order.TaxRatePercent,
+		Net:             order.NetTotal,
+		Tax:             order.TaxTotal,
+		Total:           order.Total,
+	}
+	// Órdenes anteriores a la dirección estructurada solo tienen el texto libre
+	if order.ShippingDetails.Street == "" {
+		doc.ShippingAddress = order.ShippingAddress
+	}
+
+	for _, item := range order.OrderItems {
+		doc.Lines = append(doc.Lines, Line{
+			Description: item.DisplayName(),
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Amount:      item.UnitPrice * int64(item.Quantity),
+		})
+	}
+	return doc
+}
+
+// FormattedNumber rellena el folio a 8 dígitos
+func (d Document) FormattedNumber() string {
+	return fmt.Sprintf("%08d", d.Number)
+}
+
+// FormatCLP formatea un monto en pesos con separador de miles: $12.345
+func FormatCLP(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "$" + b.String()
+}
+
+const dateLayout = "02/01/2006 15:04"
@@ -0,0 +1,56 @@
+package receipts
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"clp": FormatCLP,
+}).Parse(`<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<title>Comprobante N° {{.FormattedNumber}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 720px; margin: 24px auto; }
+h1 { font-size: 20px; margin-bottom: 4px; }
+table { width: 100%; border-collapse: collapse; margin-top: 16px; }
+th, td { padding: 6px 4px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num, th.num { text-align: right; }
+.totals td { border: none; }
+.total td { font-weight: bold; border-top: 2px solid #222; }
+</style>
+</head>
+<body>
+<h1>{{.Issuer}}</h1>
+<p>Comprobante de compra N° {{.FormattedNumber}}<br>
+Fecha de emisión: {{.IssuedAt.Format "02/01/2006 15:04"}}<br>
+Orden #{{.OrderID}}{{if .PaymentID}} · Pago Mercado Pago #{{.PaymentID}}{{end}}</p>
+<p><strong>Despacho:</strong> {{.ShippingAddress}}</p>
+<table>
+<thead><tr><th>Producto</th><th class="num">Cant.</th><th class="num">Precio unitario</th><th class="num">Total</th></tr></thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="num">{{.Quantity}}</td><td class="num">{{clp .UnitPrice}}</td><td class="num">{{clp .Amount}}</td></tr>
+{{end}}</tbody>
+</table>
+<table class="totals">
+<tr><td>Subtotal</td><td class="num">{{clp .Subtotal}}</td></tr>
+{{if .Discount}}<tr><td>Descuentos{{if .CouponCode}} ({{.CouponCode}}){{end}}</td><td class="num">-{{clp .Discount}}</td></tr>
+{{end}}{{if .ShippingCost}}<tr><td>Envío{{if .ShippingName}} ({{.ShippingName}}){{end}}</td><td class="num">{{clp .ShippingCost}}</td></tr>
+{{end}}<tr><td>Neto</td><td class="num">{{clp .Net}}</td></tr>
+<tr><td>IVA ({{.TaxRatePercent}}%)</td><td class="num">{{clp .Tax}}</td></tr>
+<tr class="total"><td>Total</td><td class="num">{{clp .Total}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// RenderHTML arma el comprobante en HTML
+func RenderHTML(doc Document) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,196 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// No hay librería de PDF en el proyecto, así que se escribe un PDF 1.4 mínimo a mano: páginas A4 con
+// texto en Helvetica (WinAnsiEncoding para tildes y ñ). Alcanza para un comprobante de texto tabulado.
+
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginLeft   = 50
+	marginTop    = 790
+	marginBottom = 60
+	lineHeight   = 16
+)
+
+type pdfText struct {
+	x, y  int
+	size  int
+	bold  bool
+	text  string
+	right bool // alinear el texto a la derecha en x
+}
+
+// RenderPDF arma el comprobante en PDF
+func RenderPDF(doc Document) []byte {
+	pages := layoutPDF(doc)
+
+	var objects []string
+	// 1: catálogo, 2: árbol de páginas, 3 y 4: fuentes; luego un par (página, contenido) por página
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		objects = append(objects, fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+i*2))
+		content := pageContent(page)
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// layoutPDF reparte el comprobante en páginas; el encabezado de la tabla se repite en cada una
+func layoutPDF(doc Document) [][]pdfText {
+	var pages [][]pdfText
+	var page []pdfText
+	y := marginTop
+
+	add := func(t pdfText) { page = append(page, t) }
+	tableHeader := func() {
+		add(pdfText{x: marginLeft, y: y, size: 10, bold: true, text: "Producto"})
+		add(pdfText{x: 360, y: y, size: 10, bold: true, text: "Cant.", right: true})
+		add(pdfText{x: 460, y: y, size: 10, bold: true, text: "P. unitario", right: true})
+		add(pdfText{x: 545, y: y, size: 10, bold: true, text: "Total", right: true})
+		y -= lineHeight
+	}
+	ensureSpace := func(lines int) {
+		if y-lines*lineHeight >= marginBottom {
+			return
+		}
+		pages = append(pages, page)
+		page = nil
+		y = marginTop
+	}
+
+	add(pdfText{x: marginLeft, y: y, size: 18, bold: true, text: doc.Issuer})
+	y -= 24
+	add(pdfText{x: marginLeft, y: y, size: 12, bold: true, text: "Comprobante de compra N° " + doc.FormattedNumber()})
+	y -= lineHeight
+	add(pdfText{x: marginLeft, y: y, size: 10, text: "Fecha de emisión: " + doc.IssuedAt.Format(dateLayout)})
+	y -= lineHeight
+	orderLine := fmt.Sprintf("Orden #%d", doc.OrderID)
+	if doc.PaymentID != "" {
+		orderLine += " - Pago Mercado Pago #" + doc.PaymentID
+	}
+	add(pdfText{x: marginLeft, y: y, size: 10, text: orderLine})
+	y -= lineHeight
+	add(pdfText{x: marginLeft, y: y, size: 10, text: "Despacho: " + doc.ShippingAddress})
+	y -= lineHeight * 2
+
+	tableHeader()
+	for _, line := range doc.Lines {
+		if y-lineHeight < marginBottom {
+			ensureSpace(2)
+			tableHeader()
+		}
+		add(pdfText{x: marginLeft, y: y, size: 10, text: truncate(line.Description, 55)})
+		add(pdfText{x: 360, y: y, size: 10, text: fmt.Sprintf("%d", line.Quantity), right: true})
+		add(pdfText{x: 460, y: y, size: 10, text: FormatCLP(line.UnitPrice), right: true})
+		add(pdfText{x: 545, y: y, size: 10, text: FormatCLP(line.Amount), right: true})
+		y -= lineHeight
+	}
+	y -= lineHeight
+
+	totals := [][2]string{{"Subtotal", FormatCLP(doc.Subtotal)}}
+	if doc.Discount > 0 {
+		label := "Descuentos"
+		if doc.CouponCode != "" {
+			label += " (" + doc.CouponCode + ")"
+		}
+		totals = append(totals, [2]string{label, "-" + FormatCLP(doc.Discount)})
+	}
+	if doc.ShippingCost > 0 {
+		label := "Envío"
+		if doc.ShippingName != "" {
+			label += " (" + doc.ShippingName + ")"
+		}
+		totals = append(totals, [2]string{label, FormatCLP(doc.ShippingCost)})
+	}
+	totals = append(totals,
+		[2]string{"Neto", FormatCLP(doc.Net)},
+		[2]string{fmt.Sprintf("IVA (%d%%)", doc.TaxRatePercent), FormatCLP(doc.Tax)},
+		[2]string{"Total", FormatCLP(doc.Total)},
+	)
+
+	ensureSpace(len(totals))
+	for i, row := range totals {
+		bold := i == len(totals)-1
+		add(pdfText{x: 360, y: y, size: 10, bold: bold, text: row[0]})
+		add(pdfText{x: 545, y: y, size: 10, bold: bold, text: row[1], right: true})
+		y -= lineHeight
+	}
+
+	return append(pages, page)
+}
+
+func pageContent(texts []pdfText) string {
+	var b strings.Builder
+	for _, t := range texts {
+		font := "F1"
+		if t.bold {
+			font = "F2"
+		}
+		x := t.x
+		if t.right {
+			// Aproximación del ancho de Helvetica: ~0.5 del tamaño por carácter
+			x -= len([]rune(t.text)) * t.size / 2
+		}
+		fmt.Fprintf(&b, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, t.size, x, t.y, pdfEscape(t.text))
+	}
+	return b.String()
+}
+
+// pdfEscape pasa el texto a WinAnsi (cp1252) y escapa los caracteres especiales de los strings PDF
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xFF:
+			// Latin-1 coincide con cp1252 en este rango (tildes, ñ, °)
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+// AnalyticsRepository agrega las órdenes directamente en SQL; nunca carga las órdenes en memoria.
+// Las órdenes de cambio (exchange_for_order_id) no son ventas y quedan fuera de todas las consultas.
+type AnalyticsRepository interface {
+	SalesByPeriod(ctx context.Context, query models.AnalyticsQuery) ([]models.SalesBucket, error)
+	TopProducts(ctx context.Context, query models.AnalyticsQuery) ([]models.ProductSales, error)
+	Conversion(ctx context.Context, query models.AnalyticsQuery) (*models.ConversionStats, error)
+}
+
+type PostgresAnalyticsRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresAnalyticsRepository() AnalyticsRepository {
+	return &PostgresAnalyticsRepository{
+		DB: database.DB,
+	}
+}
+
+// SalesByPeriod devuelve un bucket por período con ventas; Period viene como hora local de query.Timezone sin zona
+func (r *PostgresAnalyticsRepository) SalesByPeriod(ctx context.Context, query models.AnalyticsQuery) ([]models.SalesBucket, error) {
+	refunds := r.DB.Table("refunds").
+		Select("order_id, SUM(amount) AS refunded").
+		Where("deleted_at IS NULL").
+		Group("order_id")
+
+	buckets := []models.SalesBucket{}
+	result := r.soldOrders(ctx, query).
+		Select("date_trunc(?, o.created_at AT TIME ZONE ?) AS period, COUNT(*) AS orders, COALESCE(SUM(o.total), 0)::bigint AS revenue, COALESCE(SUM(r.refunded), 0)::bigint AS refunded", query.Granularity, query.Timezone).
+		Joins("LEFT JOIN (?) AS r ON r.order_id = o.id", refunds).
+		Group("period").
+		Order("period").
+		Scan(&buckets)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al calcular las ventas por período: %w", result.Error)
+	}
+	return buckets, nil
+}
+
+func (r *PostgresAnalyticsRepository) TopProducts(ctx context.Context, query models.AnalyticsQuery) ([]models.ProductSales, error) {
+	orderBy := "units DESC, revenue DESC"
+	if query.SortBy == models.AnalyticsSortRevenue {
+		orderBy = "revenue DESC, units DESC"
+	}
+
+	products := []models.ProductSales{}
+	result := r.soldOrders(ctx, query).
+		Select("oi.product_id, MAX(oi.name_snapshot) AS name_snapshot, SUM(oi.quantity)::bigint AS units, COUNT(DISTINCT o.id) AS orders, COALESCE(SUM(oi.unit_price * oi.quantity), 0)::bigint AS revenue").
+		Joins("JOIN order_items oi ON oi.order_id = o.id AND oi.deleted_at IS NULL").
+		Group("oi.product_id").
+		Order(orderBy + ", oi.product_id").
+		Limit(query.Limit).
+		Scan(&products)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al calcular los productos más vendidos: %w", result.Error)
+	}
+	return products, nil
+}
+
+// Conversion usa el historial para saber si una orden pasó por PAGADO o STOCK_FALLIDO aunque hoy esté en
+// otro estado; payment_id y el estado actual cubren las órdenes anteriores al historial.
+func (r *PostgresAnalyticsRepository) Conversion(ctx context.Context, query models.AnalyticsQuery) (*models.ConversionStats, error) {
+	reached := "EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = o.id AND h.to_status = ?)"
+
+	stats := &models.ConversionStats{}
+	result := r.orders(ctx, query).
+		Select(
+			"COUNT(*) AS created, "+
+				"COUNT(*) FILTER (WHERE o.payment_id <> '' OR "+reached+") AS paid, "+
+				"COUNT(*) FILTER (WHERE o.status = ? OR "+reached+") AS stock_failed, "+
+				"COUNT(*) FILTER (WHERE o.status = ?) AS pending, "+
+				"COUNT(*) FILTER (WHERE o.status = ?) AS expired, "+
+				"COUNT(*) FILTER (WHERE o.status = ?) AS cancelled",
+			models.OrderStatusPagado,
+			models.OrderStatusStockFallido, models.OrderStatusStockFallido,
+			models.OrderStatusPendiente,
+			models.OrderStatusExpirado,
+			models.OrderStatusCancelado,
+		).
+		Scan(stats)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al calcular la conversión de órdenes: %w", result.Error)
+	}
+	return stats, nil
+}
+
+// orders arma la base común: órdenes de venta del rango pedido
+func (r *PostgresAnalyticsRepository) orders(ctx context.Context, query models.AnalyticsQuery) *gorm.DB {
+	db := r.DB.WithContext(ctx).
+		Table("orders AS o").
+		Where("o.deleted_at IS NULL AND o.exchange_for_order_id IS NULL")
+	if query.CreatedFrom != nil {
+		db = db.Where("o.created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("o.created_at < ?", *query.CreatedTo)
+	}
+	return db
+}
+
+func (r *PostgresAnalyticsRepository) soldOrders(ctx context.Context, query models.AnalyticsQuery) *gorm.DB {
+	return r.orders(ctx, query).Where("o.status IN ?", models.SoldOrderStatuses)
+}
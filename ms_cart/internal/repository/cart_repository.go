@@ -3,8 +3,9 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
-	"strconv"
 	"fmt"
 
 	"github.com/go-redis/redis/v8"
@@ -12,10 +13,26 @@ import (
 	"github.com/C0kke/FitFashion/ms_cart/pkg/database" 
 )
 
+// ErrCartVersionConflict indica que el carrito cambió entre la lectura y el guardado
+var ErrCartVersionConflict = errors.New("el carrito fue modificado por otra operación")
+
 type CartRepository interface {
+	// Save guarda el carrito solo si la versión almacenada coincide con cart.Version.
+	// Un carrito sin items se guarda vacío (sin cupón) conservando la versión: borrar la clave la
+	// reiniciaría en 0 y un escritor que leyó la versión 0 antes podría pisar cambios posteriores.
+	// Devuelve ErrCartVersionConflict si hubo otra escritura.
 	Save(ctx context.Context, cart *models.Cart) error
+	// FindByUserID acepta tanto IDs de usuario como IDs de invitado (models.GuestCartID)
 	FindByUserID(ctx context.Context, userID string) (*models.Cart, error)
+	// DeleteByUserID vacía el carrito con las mismas reglas de versión que Save
 	DeleteByUserID(ctx context.Context, userID string) error
+	// ScanIdle recorre los carritos sin cambios desde before
+	ScanIdle(ctx context.Context, before time.Time, fn func(cart *models.Cart) error) error
+	// MarkAbandonedNotified registra que ya se avisó el abandono de esta versión del carrito;
+	// devuelve false si ya estaba registrado
+	MarkAbandonedNotified(ctx context.Context, cart *models.Cart) (bool, error)
+	// ClearAbandonedNotified borra la marca de MarkAbandonedNotified para que el aviso se reintente
+	ClearAbandonedNotified(ctx context.Context, cart *models.Cart) error
 }
 
 type RedisCartRepository struct {
@@ -31,34 +48,85 @@ func NewRedisCartRepository() CartRepository {
 }
 
 const cartKeyPrefix = "cart:"
+const abandonedKeyPrefix = "cart_abandoned:"
 
 func getCartKey(userID string) string {
 	return cartKeyPrefix + userID
 }
 
 func (r *RedisCartRepository) Save(ctx context.Context, cart *models.Cart) error {
-	cartJSON, err := json.Marshal(cart)
+	key := getCartKey(cart.UserID)
+
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		storedVersion, err := r.currentVersion(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if storedVersion != cart.Version {
+			return ErrCartVersionConflict
+		}
+
+		next := *cart
+		if len(next.Items) == 0 {
+			next = models.Cart{UserID: cart.UserID, Items: []models.CartItem{}}
+		}
+		next.Version = cart.Version + 1
+		next.LastUpdated = time.Now()
+
+		cartJSON, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, cartJSON, r.ttl)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		cart.Version = next.Version
+		cart.LastUpdated = next.LastUpdated
+		cart.CouponCode = next.CouponCode
+		return nil
+	}, key)
+
+	if err == redis.TxFailedErr {
+		return ErrCartVersionConflict
+	}
+	return err
+}
+
+// currentVersion lee la versión guardada dentro del WATCH; una clave inexistente equivale a versión 0.
+func (r *RedisCartRepository) currentVersion(ctx context.Context, tx *redis.Tx, key string) (int64, error) {
+	val, err := tx.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	userIDStr := strconv.Itoa(cart.UserID)
-	
-	return r.client.Set(ctx, getCartKey(userIDStr), cartJSON, r.ttl).Err()
+	var stored struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(val), &stored); err != nil {
+		return 0, fmt.Errorf("carrito almacenado corrupto: %w", err)
+	}
+	return stored.Version, nil
 }
 
 func (r *RedisCartRepository) FindByUserID(ctx context.Context, userID string) (*models.Cart, error) {
 	val, err := r.client.Get(ctx, getCartKey(userID)).Result()
 	
 	if err == redis.Nil {
-		userIDInt, err := strconv.Atoi(userID)
+		if userID == "" {
+			return nil, fmt.Errorf("id de carrito vacío")
+		}
 
-		if err != nil {
-            return nil, fmt.Errorf("id de usuario inválido en la clave: %w", err)
-        }
-		
 		return &models.Cart{
-            UserID: userIDInt, 
+            UserID: userID, 
             Items: []models.CartItem{}, 
             LastUpdated: time.Now(),
         }, nil
@@ -68,10 +136,65 @@ func (r *RedisCartRepository) FindByUserID(ctx context.Context, userID string) (
 	}
 
 	cart := &models.Cart{}
-	err = json.Unmarshal([]byte(val), cart)
-	return cart, err
+	if err := json.Unmarshal([]byte(val), cart); err != nil {
+		return nil, err
+	}
+	cart.UserID = userID
+	return cart, nil
 }
 
 func (r *RedisCartRepository) DeleteByUserID(ctx context.Context, userID string) error {
-	return r.client.Del(ctx, getCartKey(userID)).Err()
-}
\ No newline at end of file
+	for attempt := 0; attempt < 3; attempt++ {
+		cart, err := r.FindByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		cart.Items = []models.CartItem{}
+		err = r.Save(ctx, cart)
+		if !errors.Is(err, ErrCartVersionConflict) {
+			return err
+		}
+	}
+	return ErrCartVersionConflict
+}
+
+func (r *RedisCartRepository) ScanIdle(ctx context.Context, before time.Time, fn func(cart *models.Cart) error) error {
+	iter := r.client.Scan(ctx, 0, cartKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		val, err := r.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		cart := &models.Cart{}
+		if err := json.Unmarshal([]byte(val), cart); err != nil {
+			continue
+		}
+		cart.UserID = strings.TrimPrefix(key, cartKeyPrefix)
+
+		if len(cart.Items) == 0 || cart.LastUpdated.After(before) {
+			continue
+		}
+		if err := fn(cart); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (r *RedisCartRepository) MarkAbandonedNotified(ctx context.Context, cart *models.Cart) (bool, error) {
+	return r.client.SetNX(ctx, abandonedKey(cart), 1, r.ttl).Result()
+}
+
+func (r *RedisCartRepository) ClearAbandonedNotified(ctx context.Context, cart *models.Cart) error {
+	return r.client.Del(ctx, abandonedKey(cart)).Err()
+}
+
+// abandonedKey identifica el aviso de abandono de una versión del carrito
+func abandonedKey(cart *models.Cart) string {
+	return fmt.Sprintf("%s%s:%d", abandonedKeyPrefix, cart.UserID, cart.LastUpdated.Unix())
+}
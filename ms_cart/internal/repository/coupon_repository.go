@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+var ErrCouponNotFound = errors.New("cupón no encontrado")
+
+type CouponRepository interface {
+	Create(ctx context.Context, coupon *models.Coupon) error
+	FindByCode(ctx context.Context, code string) (*models.Coupon, error)
+	// CountUserRedemptions cuenta los usos del cupón en órdenes que no terminaron fallidas
+	CountUserRedemptions(ctx context.Context, couponID uint, userID uint) (int64, error)
+	CreateRedemption(ctx context.Context, redemption *models.CouponRedemption) error
+}
+
+type PostgresCouponRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresCouponRepository() CouponRepository {
+	return &PostgresCouponRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresCouponRepository) Create(ctx context.Context, coupon *models.Coupon) error {
+	coupon.Code = strings.ToUpper(strings.TrimSpace(coupon.Code))
+	if err := r.DB.WithContext(ctx).Create(coupon).Error; err != nil {
+		return fmt.Errorf("error al crear el cupón: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresCouponRepository) FindByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	coupon := &models.Coupon{}
+	result := r.DB.WithContext(ctx).Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).First(coupon)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, result.Error
+	}
+	return coupon, nil
+}
+
+func (r *PostgresCouponRepository) CountUserRedemptions(ctx context.Context, couponID uint, userID uint) (int64, error) {
+	var count int64
+	result := r.DB.WithContext(ctx).
+		Model(&models.CouponRedemption{}).
+		Joins("JOIN orders ON orders.id = coupon_redemptions.order_id").
+		Where("coupon_redemptions.coupon_id = ? AND coupon_redemptions.user_id = ?", couponID, userID).
+		// El uso se registra al crear la orden: las que nunca llegaron a venderse no lo consumen
+		Where("orders.status NOT IN ?", []models.OrderStatus{models.OrderStatusRechazado, models.OrderStatusCancelado, models.OrderStatusExpirado, models.OrderStatusStockFallido}).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("error al contar usos del cupón: %w", result.Error)
+	}
+	return count, nil
+}
+
+func (r *PostgresCouponRepository) CreateRedemption(ctx context.Context, redemption *models.CouponRedemption) error {
+	if err := r.DB.WithContext(ctx).Create(redemption).Error; err != nil {
+		return fmt.Errorf("error al registrar uso del cupón: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("cursor de paginación inválido")
+
+// orderCursor es la posición de la última orden de una página (paginación por keyset sobre
+// el campo de orden + ID). Guarda el orden usado para rechazar cursores de otra consulta.
+type orderCursor struct {
+	SortBy    string    `json:"s"`
+	SortDir   string    `json:"d"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Total     int64     `json:"t,omitempty"`
+	ID        uint      `json:"i"`
+}
+
+func encodeOrderCursor(query *models.OrderQuery, last *models.Order) string {
+	cursor := orderCursor{SortBy: query.SortBy, SortDir: query.SortDir, ID: last.ID}
+	if query.SortBy == models.OrderSortTotal {
+		cursor.Total = last.Total
+	} else {
+		cursor.CreatedAt = last.CreatedAt
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeOrderCursor(query *models.OrderQuery) (*orderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor orderCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortBy != query.SortBy || cursor.SortDir != query.SortDir {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -4,18 +4,36 @@ import (
 	"context"
 	"errors" 
 	"fmt"
+	"time"
 	"gorm.io/gorm" 
+	"gorm.io/gorm/clause"
 	
 	"github.com/C0kke/FitFashion/ms_cart/internal/models"
 	"github.com/C0kke/FitFashion/ms_cart/pkg/database" 
 )
 
 type OrderRepository interface {
-	Create(ctx context.Context, order *models.Order) error 
+	// Create guarda la orden junto con la primera fila de su historial de estados
+	Create(ctx context.Context, order *models.Order, change models.StatusChange) error 
 	FindByID(ctx context.Context, orderID uint) (*models.Order, error)
-	FindByUserID(ctx context.Context, userID uint) ([]models.Order, error)
-	UpdateStatus(ctx context.Context, orderID uint, status string) error
-	FindAll(ctx context.Context) ([]models.Order, error)
+	// UpdateStatus aplica el cambio solo si la transición es válida desde el estado actual
+	// (update condicional); devuelve *models.InvalidStatusTransitionError si no lo es, o
+	// *models.StatusChangedError si change.ExpectedStatus no coincide con el estado actual.
+	// El historial se escribe en la misma transacción.
+	UpdateStatus(ctx context.Context, orderID uint, status models.OrderStatus, change models.StatusChange) error
+	FindStatusHistory(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error)
+	SetPaymentURL(ctx context.Context, orderID uint, paymentURL string) error
+	// FindByIdempotencyKey busca la orden más reciente del usuario con esa clave creada después de since
+	// que llegó a tener URL de pago; devuelve (nil, nil) si no existe.
+	FindByIdempotencyKey(ctx context.Context, userID uint, key string, since time.Time) (*models.Order, error)
+	// FindExpiredUnpaid devuelve órdenes pendientes o rechazadas cuyo plazo de pago venció antes de now.
+	// Las órdenes antiguas sin expires_at vencen ttl después de creadas.
+	FindExpiredUnpaid(ctx context.Context, now time.Time, ttl time.Duration, limit int) ([]models.Order, error)
+	// FindPage devuelve una página de órdenes (con items) según los filtros de query, ya normalizada
+	FindPage(ctx context.Context, query *models.OrderQuery) (*models.OrderPage, error)
+	// SumPurchasedQuantities suma las unidades por producto en las órdenes vigentes del usuario
+	// (models.PurchaseLimitOrderStatuses)
+	SumPurchasedQuantities(ctx context.Context, userID uint, productIDs []string) (map[string]int, error)
 }
 
 
@@ -29,15 +47,27 @@ func NewPostgresOrderRepository() OrderRepository {
 	}
 }
 
-func (r *PostgresOrderRepository) Create(ctx context.Context, order *models.Order) error {
-	result := r.DB.WithContext(ctx).Create(order) 
-	
-	if result.Error != nil {
-		return fmt.Errorf("error al crear la orden en PostgreSQL: %w", result.Error)
+func (r *PostgresOrderRepository) Create(ctx context.Context, order *models.Order, change models.StatusChange) error {
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createOrder(tx, order, change)
+	})
+	if err != nil {
+		return fmt.Errorf("error al crear la orden en PostgreSQL: %w", err)
 	}
 	return nil
 }
 
+// createOrder inserta la orden con su primera fila de historial y su evento dentro de tx
+func createOrder(tx *gorm.DB, order *models.Order, change models.StatusChange) error {
+	if err := tx.Create(order).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(newStatusHistory(order.ID, "", order.Status, change)).Error; err != nil {
+		return err
+	}
+	return enqueueOrderEvent(tx, order, change.EventType)
+}
+
 func (r *PostgresOrderRepository) FindByID(ctx context.Context, orderID uint) (*models.Order, error) {
 	order := &models.Order{}
 	
@@ -51,46 +81,229 @@ func (r *PostgresOrderRepository) FindByID(ctx context.Context, orderID uint) (*
 	return order, nil
 }
 
-func (r *PostgresOrderRepository) FindByUserID(ctx context.Context, userID uint) ([]models.Order, error) {
-	var orders []models.Order
-	
-	result := r.DB.WithContext(ctx).Where("user_id = ?", userID).Preload("OrderItems").Find(&orders)
+func (r *PostgresOrderRepository) UpdateStatus(ctx context.Context, orderID uint, status models.OrderStatus, change models.StatusChange) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+		return transitionOrderStatus(tx, current, status, change)
+	})
+}
 
+// lockOrder lee la orden con SELECT ... FOR UPDATE para serializar los cambios de estado concurrentes
+func lockOrder(tx *gorm.DB, orderID uint) (*models.Order, error) {
+	order := &models.Order{}
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(order, orderID)
 	if result.Error != nil {
-		return nil, result.Error
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("orden no encontrada con ID: %d", orderID)
+		}
+		return nil, fmt.Errorf("error al leer el estado de la orden: %w", result.Error)
 	}
-	return orders, nil
+	return order, nil
 }
 
-func (r *PostgresOrderRepository) UpdateStatus(ctx context.Context, orderID uint, status string) error {
-	result := r.DB.WithContext(ctx).Model(&models.Order{}).Where("id = ?", orderID).Update("status", status)
-	
+// transitionOrderStatus valida y aplica el cambio sobre una orden ya bloqueada en tx, escribiendo
+// el historial y el evento del outbox en la misma transacción
+func transitionOrderStatus(tx *gorm.DB, current *models.Order, status models.OrderStatus, change models.StatusChange) error {
+	if err := change.Check(current.ID, current.Status, status); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == models.OrderStatusPagado && change.PaymentID != "" {
+		updates["payment_id"] = change.PaymentID
+	}
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", current.ID, current.Status).
+		Updates(updates)
 	if result.Error != nil {
 		return fmt.Errorf("error al actualizar el estado de la orden: %w", result.Error)
 	}
-	
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("orden no encontrada con ID: %d", orderID)
+		return fmt.Errorf("el estado de la orden #%d cambió concurrentemente, no se pudo actualizar", current.ID)
+	}
+	if err := tx.Create(newStatusHistory(current.ID, current.Status, status, change)).Error; err != nil {
+		return fmt.Errorf("error al registrar el historial de la orden: %w", err)
+	}
+	if change.EventType == "" {
+		return nil
+	}
+
+	var order models.Order
+	if err := tx.Preload("OrderItems").First(&order, current.ID).Error; err != nil {
+		return err
+	}
+	return enqueueOrderEvent(tx, &order, change.EventType)
+}
+
+func (r *PostgresOrderRepository) FindStatusHistory(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error) {
+	history := []models.OrderStatusHistory{}
+	result := r.DB.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("created_at ASC, id ASC").
+		Find(&history)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al leer el historial de la orden: %w", result.Error)
+	}
+	return history, nil
+}
+
+func (r *PostgresOrderRepository) SetPaymentURL(ctx context.Context, orderID uint, paymentURL string) error {
+	result := r.DB.WithContext(ctx).Model(&models.Order{}).Where("id = ?", orderID).Update("payment_url", paymentURL)
+	if result.Error != nil {
+		return fmt.Errorf("error al guardar la URL de pago: %w", result.Error)
 	}
-	
 	return nil
 }
 
-func (r *PostgresOrderRepository) FindAll(ctx context.Context) ([]models.Order, error) {
+func (r *PostgresOrderRepository) FindByIdempotencyKey(ctx context.Context, userID uint, key string, since time.Time) (*models.Order, error) {
+	order := &models.Order{}
+	result := r.DB.WithContext(ctx).
+		Where("user_id = ? AND idempotency_key = ? AND created_at >= ? AND payment_url <> ''", userID, key, since).
+		Order("created_at DESC").
+		First(order)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error al buscar la orden por clave de idempotencia: %w", result.Error)
+	}
+	return order, nil
+}
+
+// enqueueOrderEvent inserta el evento en el outbox usando la transacción del cambio de la orden
+func enqueueOrderEvent(tx *gorm.DB, order *models.Order, eventType string) error {
+	if eventType == "" {
+		return nil
+	}
+	event, err := models.NewOrderOutboxEvent(eventType, order)
+	if err != nil {
+		return err
+	}
+	return tx.Create(event).Error
+}
+
+func newStatusHistory(orderID uint, from, to models.OrderStatus, change models.StatusChange) *models.OrderStatusHistory {
+	return &models.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		Source:     change.Source,
+		PaymentID:  change.PaymentID,
+		Reason:     change.Reason,
+	}
+}
+
+func (r *PostgresOrderRepository) FindExpiredUnpaid(ctx context.Context, now time.Time, ttl time.Duration, limit int) ([]models.Order, error) {
 	var orders []models.Order
 	result := r.DB.WithContext(ctx).
+		Where("status IN ?", []models.OrderStatus{models.OrderStatusPendiente, models.OrderStatusRechazado}).
+		Where("expires_at < ? OR (expires_at IS NULL AND created_at < ?)", now, now.Add(-ttl)).
+		Order("id").
+		Limit(limit).
 		Preload("OrderItems").
-		Find(&orders) 
-		
+		Find(&orders)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, fmt.Errorf("error al buscar órdenes vencidas: %w", result.Error)
+	}
+	return orders, nil
+}
+
+func (r *PostgresOrderRepository) FindPage(ctx context.Context, query *models.OrderQuery) (*models.OrderPage, error) {
+	db := r.DB.WithContext(ctx).Model(&models.Order{})
+
+	if query.UserID != 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("created_at < ?", *query.CreatedTo)
+	}
+	if query.MinTotal != nil {
+		db = db.Where("total >= ?", *query.MinTotal)
+	}
+	if query.MaxTotal != nil {
+		db = db.Where("total <= ?", *query.MaxTotal)
+	}
+	if query.ProductID != "" {
+		db = db.Where("EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.deleted_at IS NULL AND order_items.product_id = ?)", query.ProductID)
 	}
 
+	// query.SortBy y SortDir ya vienen validados por Normalize, así que es seguro interpolarlos
+	column := query.SortBy
+	comparator := "<"
+	if query.SortDir == "asc" {
+		comparator = ">"
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeOrderCursor(query)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = cursor.CreatedAt
+		if column == models.OrderSortTotal {
+			value = cursor.Total
+		}
+		db = db.Where("("+column+", id) "+comparator+" (?, ?)", value, cursor.ID)
+	}
+
+	orders := []models.Order{}
+	result := db.
+		Order(column + " " + query.SortDir).
+		Order("id " + query.SortDir).
+		Limit(query.Limit + 1).
+		Preload("OrderItems").
+		Find(&orders)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al listar órdenes: %w", result.Error)
+	}
+
+	page := &models.OrderPage{Limit: query.Limit, SortBy: query.SortBy, SortDir: query.SortDir}
+	if len(orders) > query.Limit {
+		orders = orders[:query.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeOrderCursor(query, &orders[len(orders)-1])
+	}
 	for i := range orders {
 		if orders[i].OrderItems == nil {
-			orders[i].OrderItems = []models.OrderItem{} 
+			orders[i].OrderItems = []models.OrderItem{}
 		}
 	}
+	page.Orders = orders
+	return page, nil
+}
 
-	return orders, nil
-}
\ No newline at end of file
+func (r *PostgresOrderRepository) SumPurchasedQuantities(ctx context.Context, userID uint, productIDs []string) (map[string]int, error) {
+	quantities := make(map[string]int, len(productIDs))
+	if len(productIDs) == 0 {
+		return quantities, nil
+	}
+
+	var rows []struct {
+		ProductID string
+		Total     int
+	}
+	result := r.DB.WithContext(ctx).
+		Model(&models.OrderItem{}).
+		Select("order_items.product_id AS product_id, COALESCE(SUM(order_items.quantity), 0) AS total").
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.user_id = ? AND orders.status IN ?", userID, models.PurchaseLimitOrderStatuses).
+		Where("order_items.product_id IN ?", productIDs).
+		Group("order_items.product_id").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al sumar compras del usuario: %w", result.Error)
+	}
+
+	for _, row := range rows {
+		quantities[row.ProductID] = row.Total
+	}
+	return quantities, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+type OutboxRepository interface {
+	// ClaimPending toma hasta limit eventos pendientes y corre su próximo intento a now+lease,
+	// para que otra instancia del relay no los publique al mismo tiempo
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkSent(ctx context.Context, eventID uint) error
+	MarkFailed(ctx context.Context, eventID uint, lastError string, nextAttemptAt time.Time) error
+}
+
+type PostgresOutboxRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresOutboxRepository() OutboxRepository {
+	return &PostgresOutboxRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresOutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	now := time.Now()
+	var events []models.OutboxEvent
+	result := r.DB.WithContext(ctx).Raw(`
+		UPDATE outbox_events SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), models.OutboxPending, now, limit).
+		Scan(&events)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al tomar eventos pendientes del outbox: %w", result.Error)
+	}
+	return events, nil
+}
+
+func (r *PostgresOutboxRepository) MarkSent(ctx context.Context, eventID uint) error {
+	now := time.Now()
+	result := r.DB.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id = ?", eventID).
+		Updates(map[string]interface{}{
+			"status":     models.OutboxSent,
+			"sent_at":    now,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": "",
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error al marcar el evento %d como enviado: %w", eventID, result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresOutboxRepository) MarkFailed(ctx context.Context, eventID uint, lastError string, nextAttemptAt time.Time) error {
+	result := r.DB.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id = ?", eventID).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      lastError,
+			"next_attempt_at": nextAttemptAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error al registrar el fallo del evento %d: %w", eventID, result.Error)
+	}
+	return nil
+}
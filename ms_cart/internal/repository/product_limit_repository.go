@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+type ProductLimitRepository interface {
+	FindByProductIDs(ctx context.Context, productIDs []string) ([]models.ProductPurchaseLimit, error)
+	Upsert(ctx context.Context, limit *models.ProductPurchaseLimit) error
+	Delete(ctx context.Context, productID string) error
+}
+
+type PostgresProductLimitRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresProductLimitRepository() ProductLimitRepository {
+	return &PostgresProductLimitRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresProductLimitRepository) FindByProductIDs(ctx context.Context, productIDs []string) ([]models.ProductPurchaseLimit, error) {
+	var limits []models.ProductPurchaseLimit
+	if len(productIDs) == 0 {
+		return limits, nil
+	}
+	result := r.DB.WithContext(ctx).Where("product_id IN ?", productIDs).Find(&limits)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return limits, nil
+}
+
+func (r *PostgresProductLimitRepository) Upsert(ctx context.Context, limit *models.ProductPurchaseLimit) error {
+	result := r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_per_user", "updated_at", "deleted_at"}),
+	}).Create(limit)
+	if result.Error != nil {
+		return fmt.Errorf("error al guardar límite del producto %s: %w", limit.ProductID, result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresProductLimitRepository) Delete(ctx context.Context, productID string) error {
+	result := r.DB.WithContext(ctx).Unscoped().Where("product_id = ?", productID).Delete(&models.ProductPurchaseLimit{})
+	if result.Error != nil {
+		return fmt.Errorf("error al eliminar límite del producto %s: %w", productID, result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+var ErrReceiptNotFound = errors.New("comprobante no encontrado")
+
+// receiptNumberLock es la clave del advisory lock que serializa la asignación de folios
+const receiptNumberLock = 7_300_023
+
+type ReceiptRepository interface {
+	FindByOrderID(ctx context.Context, orderID uint) (*models.Receipt, error)
+	// CreateWithNextNumber asigna el siguiente folio y llama a render para completar el documento antes de
+	// guardarlo, todo bajo un lock para que los folios no se repitan ni tengan saltos. Si la orden ya tenía
+	// comprobante devuelve ese.
+	CreateWithNextNumber(ctx context.Context, orderID uint, render func(receipt *models.Receipt) error) (*models.Receipt, error)
+}
+
+type PostgresReceiptRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresReceiptRepository() ReceiptRepository {
+	return &PostgresReceiptRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresReceiptRepository) FindByOrderID(ctx context.Context, orderID uint) (*models.Receipt, error) {
+	return findReceipt(r.DB.WithContext(ctx), orderID)
+}
+
+func (r *PostgresReceiptRepository) CreateWithNextNumber(ctx context.Context, orderID uint, render func(receipt *models.Receipt) error) (*models.Receipt, error) {
+	var receipt *models.Receipt
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", receiptNumberLock).Error; err != nil {
+			return fmt.Errorf("error al bloquear la numeración de comprobantes: %w", err)
+		}
+
+		existing, err := findReceipt(tx, orderID)
+		if err == nil {
+			receipt = existing
+			return nil
+		}
+		if !errors.Is(err, ErrReceiptNotFound) {
+			return err
+		}
+
+		var last int64
+		if err := tx.Model(&models.Receipt{}).Unscoped().Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return fmt.Errorf("error al leer el último folio: %w", err)
+		}
+
+		receipt = &models.Receipt{OrderID: orderID, Number: last + 1}
+		if err := render(receipt); err != nil {
+			return err
+		}
+		if err := tx.Create(receipt).Error; err != nil {
+			return fmt.Errorf("error al guardar el comprobante: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func findReceipt(db *gorm.DB, orderID uint) (*models.Receipt, error) {
+	receipt := &models.Receipt{}
+	result := db.Where("order_id = ?", orderID).First(receipt)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrReceiptNotFound
+		}
+		return nil, result.Error
+	}
+	return receipt, nil
+}
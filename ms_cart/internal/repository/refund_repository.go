@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+type RefundRepository interface {
+	Create(ctx context.Context, refund *models.Refund) error
+	FindByOrderID(ctx context.Context, orderID uint) ([]models.Refund, error)
+	// FindByIdempotencyKey devuelve el reembolso emitido con esa clave, o (nil, nil) si no existe
+	FindByIdempotencyKey(ctx context.Context, key string) (*models.Refund, error)
+}
+
+type PostgresRefundRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresRefundRepository() RefundRepository {
+	return &PostgresRefundRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresRefundRepository) Create(ctx context.Context, refund *models.Refund) error {
+	if err := r.DB.WithContext(ctx).Create(refund).Error; err != nil {
+		return fmt.Errorf("error al registrar el reembolso: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRefundRepository) FindByOrderID(ctx context.Context, orderID uint) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	result := r.DB.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at").Find(&refunds)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al leer los reembolsos de la orden: %w", result.Error)
+	}
+	return refunds, nil
+}
+
+func (r *PostgresRefundRepository) FindByIdempotencyKey(ctx context.Context, key string) (*models.Refund, error) {
+	refund := &models.Refund{}
+	result := r.DB.WithContext(ctx).Where("idempotency_key = ?", key).First(refund)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error al buscar el reembolso: %w", result.Error)
+	}
+	return refund, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+var ErrReservationNotFound = errors.New("reserva de stock no encontrada")
+
+type ReservationRepository interface {
+	Create(ctx context.Context, reservation *models.StockReservation) error
+	FindByOrderID(ctx context.Context, orderID uint) (*models.StockReservation, error)
+	// Transition cambia el estado solo si la reserva sigue en from; devuelve false si otro proceso se adelantó
+	Transition(ctx context.Context, orderID uint, from string, to string, reason string) (bool, error)
+	FindExpired(ctx context.Context, now time.Time, limit int) ([]models.StockReservation, error)
+}
+
+type PostgresReservationRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresReservationRepository() ReservationRepository {
+	return &PostgresReservationRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresReservationRepository) Create(ctx context.Context, reservation *models.StockReservation) error {
+	if err := r.DB.WithContext(ctx).Create(reservation).Error; err != nil {
+		return fmt.Errorf("error al crear la reserva de stock: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresReservationRepository) FindByOrderID(ctx context.Context, orderID uint) (*models.StockReservation, error) {
+	reservation := &models.StockReservation{}
+	result := r.DB.WithContext(ctx).Where("order_id = ?", orderID).First(reservation)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrReservationNotFound
+		}
+		return nil, result.Error
+	}
+	return reservation, nil
+}
+
+func (r *PostgresReservationRepository) Transition(ctx context.Context, orderID uint, from string, to string, reason string) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	now := time.Now()
+	switch to {
+	case models.ReservationReleased:
+		updates["released_at"] = now
+		updates["reason"] = reason
+	case models.ReservationCommitted:
+		updates["committed_at"] = now
+	}
+
+	result := r.DB.WithContext(ctx).
+		Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("error al actualizar la reserva de la orden %d: %w", orderID, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *PostgresReservationRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	result := r.DB.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", models.ReservationActive, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&reservations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return reservations, nil
+}
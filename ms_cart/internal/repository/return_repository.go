@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+var ErrReturnNotFound = errors.New("devolución no encontrada")
+
+type ReturnRepository interface {
+	// CreateForOrder bloquea la orden, valida los items contra las devoluciones existentes y crea la
+	// solicitud junto con su evento return.requested
+	CreateForOrder(ctx context.Context, ret *models.ReturnRequest) error
+	FindByID(ctx context.Context, returnID uint) (*models.ReturnRequest, error)
+	FindByOrderID(ctx context.Context, orderID uint) ([]models.ReturnRequest, error)
+	FindByUserID(ctx context.Context, userID uint) ([]models.ReturnRequest, error)
+	// Transition guarda la devolución solo si sigue en from y encola eventType en la misma transacción;
+	// devuelve false si otro proceso se adelantó
+	Transition(ctx context.Context, ret *models.ReturnRequest, from string, eventType string) (bool, error)
+	// CreateExchangeOrder crea la orden de cambio de una devolución RECIBIDA y la enlaza en la misma
+	// transacción. Si la devolución ya tiene una orden de cambio vigente (reintento) la carga en exchange
+	// y devuelve false; una orden de cambio que quedó en STOCK_FALLIDO se reemplaza por la nueva.
+	CreateExchangeOrder(ctx context.Context, ret *models.ReturnRequest, exchange *models.Order, change models.StatusChange) (bool, error)
+}
+
+type PostgresReturnRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresReturnRepository() ReturnRepository {
+	return &PostgresReturnRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresReturnRepository) CreateForOrder(ctx context.Context, ret *models.ReturnRequest) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, ret.OrderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != ret.UserID {
+			return fmt.Errorf("orden no encontrada con ID: %d", ret.OrderID)
+		}
+		if !order.Status.Returnable() {
+			return fmt.Errorf("la orden #%d no admite devoluciones en estado %s", order.ID, order.Status)
+		}
+		if err := tx.Model(order).Association("OrderItems").Find(&order.OrderItems); err != nil {
+			return err
+		}
+
+		var existing []models.ReturnRequest
+		if err := tx.Where("order_id = ?", order.ID).Preload("Items").Find(&existing).Error; err != nil {
+			return fmt.Errorf("error al leer las devoluciones de la orden: %w", err)
+		}
+		if err := models.ValidateReturnItems(order, existing, ret.Resolution, ret.Items); err != nil {
+			return err
+		}
+
+		if err := tx.Create(ret).Error; err != nil {
+			return fmt.Errorf("error al crear la devolución: %w", err)
+		}
+		event, err := models.NewReturnOutboxEvent(models.ReturnEventRequested, ret)
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+}
+
+func (r *PostgresReturnRepository) FindByID(ctx context.Context, returnID uint) (*models.ReturnRequest, error) {
+	ret := &models.ReturnRequest{}
+	result := r.DB.WithContext(ctx).Preload("Items").First(ret, returnID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrReturnNotFound
+		}
+		return nil, result.Error
+	}
+	return ret, nil
+}
+
+func (r *PostgresReturnRepository) FindByOrderID(ctx context.Context, orderID uint) ([]models.ReturnRequest, error) {
+	returns := []models.ReturnRequest{}
+	result := r.DB.WithContext(ctx).Where("order_id = ?", orderID).Preload("Items").Order("created_at").Find(&returns)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al leer las devoluciones de la orden: %w", result.Error)
+	}
+	return returns, nil
+}
+
+func (r *PostgresReturnRepository) FindByUserID(ctx context.Context, userID uint) ([]models.ReturnRequest, error) {
+	returns := []models.ReturnRequest{}
+	result := r.DB.WithContext(ctx).Where("user_id = ?", userID).Preload("Items").Order("created_at DESC").Find(&returns)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al leer las devoluciones del usuario: %w", result.Error)
+	}
+	return returns, nil
+}
+
+func (r *PostgresReturnRepository) Transition(ctx context.Context, ret *models.ReturnRequest, from string, eventType string) (bool, error) {
+	claimed := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(ret).
+			Where("status = ?", from).
+			Select("Status", "AdminNote", "RefundID", "ExchangeOrderID", "ApprovedAt", "ReceivedAt", "CompletedAt").
+			Updates(ret)
+		if result.Error != nil {
+			return fmt.Errorf("error al actualizar la devolución #%d: %w", ret.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		claimed = true
+
+		if eventType == "" {
+			return nil
+		}
+		event, err := models.NewReturnOutboxEvent(eventType, ret)
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	return claimed, err
+}
+
+func (r *PostgresReturnRepository) CreateExchangeOrder(ctx context.Context, ret *models.ReturnRequest, exchange *models.Order, change models.StatusChange) (bool, error) {
+	created := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked := &models.ReturnRequest{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(locked, ret.ID).Error; err != nil {
+			return fmt.Errorf("error al leer la devolución #%d: %w", ret.ID, err)
+		}
+		if locked.Status != models.ReturnReceived {
+			return fmt.Errorf("la devolución #%d está %s, no admite orden de cambio", ret.ID, locked.Status)
+		}
+
+		if locked.ExchangeOrderID != nil {
+			previous := &models.Order{}
+			if err := tx.Preload("OrderItems").First(previous, *locked.ExchangeOrderID).Error; err != nil {
+				return fmt.Errorf("error al leer la orden de cambio #%d: %w", *locked.ExchangeOrderID, err)
+			}
+			if previous.Status != models.OrderStatusStockFallido {
+				*exchange = *previous
+				return nil
+			}
+		}
+
+		if err := createOrder(tx, exchange, change); err != nil {
+			return fmt.Errorf("error al crear la orden de cambio: %w", err)
+		}
+		if err := tx.Model(locked).Update("exchange_order_id", exchange.ID).Error; err != nil {
+			return fmt.Errorf("error al enlazar la orden de cambio a la devolución #%d: %w", ret.ID, err)
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	ret.ExchangeOrderID = &exchange.ID
+	return created, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
+)
+
+var ErrShipmentNotFound = errors.New("despacho no encontrado")
+
+type ShipmentRepository interface {
+	// CreateForOrder bloquea la orden, valida las unidades contra los despachos existentes y crea el despacho
+	CreateForOrder(ctx context.Context, shipment *models.Shipment) error
+	FindByID(ctx context.Context, shipmentID uint) (*models.Shipment, error)
+	FindByOrderID(ctx context.Context, orderID uint) ([]models.Shipment, error)
+	// SaveAndSyncOrder guarda el despacho solo si sigue en fromStatus y, en la misma transacción, encola
+	// eventType (si no está vacío) y mueve la orden a ENVIADO o ENTREGADO según el conjunto de despachos
+	// (pasando por ENVIADO si hace falta). Si la orden no admite ese estado falla sin guardar nada.
+	// Devuelve el estado final de la orden.
+	SaveAndSyncOrder(ctx context.Context, shipment *models.Shipment, fromStatus string, eventType string, change models.StatusChange) (models.OrderStatus, error)
+}
+
+type PostgresShipmentRepository struct {
+	DB *gorm.DB
+}
+
+func NewPostgresShipmentRepository() ShipmentRepository {
+	return &PostgresShipmentRepository{
+		DB: database.DB,
+	}
+}
+
+func (r *PostgresShipmentRepository) CreateForOrder(ctx context.Context, shipment *models.Shipment) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(order).Association("OrderItems").Find(&order.OrderItems); err != nil {
+			return err
+		}
+
+		existing, err := findShipments(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		items, err := models.AllocateShipmentItems(order, existing, shipment.Items)
+		if err != nil {
+			return err
+		}
+		shipment.Items = items
+
+		if err := tx.Create(shipment).Error; err != nil {
+			return fmt.Errorf("error al crear el despacho: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PostgresShipmentRepository) FindByID(ctx context.Context, shipmentID uint) (*models.Shipment, error) {
+	shipment := &models.Shipment{}
+	result := r.DB.WithContext(ctx).Preload("Items").First(shipment, shipmentID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrShipmentNotFound
+		}
+		return nil, result.Error
+	}
+	return shipment, nil
+}
+
+func (r *PostgresShipmentRepository) FindByOrderID(ctx context.Context, orderID uint) ([]models.Shipment, error) {
+	return findShipments(r.DB.WithContext(ctx), orderID)
+}
+
+func (r *PostgresShipmentRepository) SaveAndSyncOrder(ctx context.Context, shipment *models.Shipment, fromStatus string, eventType string, change models.StatusChange) (models.OrderStatus, error) {
+	var finalStatus models.OrderStatus
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Bloquear la orden serializa las actualizaciones de despachos de una misma orden
+		order, err := lockOrder(tx, shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(order).Association("OrderItems").Find(&order.OrderItems); err != nil {
+			return err
+		}
+
+		result := tx.Model(shipment).
+			Where("status = ?", fromStatus).
+			Select("Carrier", "TrackingNumber", "Status", "ShippedAt", "DeliveredAt").
+			Updates(shipment)
+		if result.Error != nil {
+			return fmt.Errorf("error al actualizar el despacho: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("el despacho #%d cambió concurrentemente, vuelve a intentarlo", shipment.ID)
+		}
+
+		if eventType != "" {
+			event, err := models.NewShipmentOutboxEvent(eventType, order, shipment)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(event).Error; err != nil {
+				return err
+			}
+		}
+
+		finalStatus = order.Status
+		// Corregir carrier o tracking no mueve la orden
+		if shipment.Status == fromStatus {
+			return nil
+		}
+
+		shipments, err := findShipments(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		target := models.OrderStatusForShipments(order, shipments)
+		if target == "" || target == order.Status {
+			return nil
+		}
+
+		// Un despacho entregado directamente desde PREPARANDO deja la orden pasando por ENVIADO
+		if target == models.OrderStatusEntregado && order.Status != models.OrderStatusEnviado && order.Status.CanTransitionTo(models.OrderStatusEnviado) {
+			if err := transitionOrderStatus(tx, order, models.OrderStatusEnviado, change); err != nil {
+				return err
+			}
+			order.Status = models.OrderStatusEnviado
+		}
+
+		orderChange := change
+		if target == models.OrderStatusEntregado {
+			orderChange.EventType = models.OrderEventDelivered
+		}
+		if err := transitionOrderStatus(tx, order, target, orderChange); err != nil {
+			return err
+		}
+		finalStatus = target
+		return nil
+	})
+	return finalStatus, err
+}
+
+func findShipments(db *gorm.DB, orderID uint) ([]models.Shipment, error) {
+	shipments := []models.Shipment{}
+	result := db.Where("order_id = ?", orderID).Preload("Items").Order("created_at, id").Find(&shipments)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error al leer los despachos de la orden: %w", result.Error)
+	}
+	return shipments, nil
+}
@@ -3,11 +3,13 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"strconv"
 	"fmt"
 
 	"github.com/streadway/amqp"
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
 	"github.com/C0kke/FitFashion/ms_cart/internal/service" 
 )
 
@@ -25,10 +27,13 @@ type Listener struct {
 	Channel *amqp.Channel
 	Service *service.CartService
 	OrderService *service.OrderService
+	ShipmentService *service.ShipmentService
+	ReturnService *service.ReturnService
+	AnalyticsService *service.AnalyticsService
 	QueueName string
 }
 
-func NewRpcListener(conn *amqp.Connection, queueName string, cartS *service.CartService, orderS *service.OrderService) (*Listener, error) {
+func NewRpcListener(conn *amqp.Connection, queueName string, cartS *service.CartService, orderS *service.OrderService, shipmentS *service.ShipmentService, returnS *service.ReturnService, analyticsS *service.AnalyticsService) (*Listener, error) {
     ch, err := conn.Channel()
     if err != nil {
         return nil, err
@@ -48,6 +53,9 @@ func NewRpcListener(conn *amqp.Connection, queueName string, cartS *service.Cart
 		Channel: ch, 
 		Service: cartS, 
 		OrderService: orderS, 
+		ShipmentService: shipmentS,
+		ReturnService: returnS,
+		AnalyticsService: analyticsS,
 		QueueName: queueName,
 	}, nil
 }
@@ -97,7 +105,15 @@ func (l *Listener) handleMessage(d amqp.Delivery) {
 	status := "success"
 	if err != nil {
 		status = "error"
-		respPayload = map[string]string{"message": err.Error()}
+		errPayload := map[string]interface{}{"message": err.Error()}
+		var coded service.CodedError
+		if errors.As(err, &coded) {
+			errPayload["code"] = coded.Code()
+			if details := coded.Details(); details != nil {
+				errPayload["details"] = details
+			}
+		}
+		respPayload = errPayload
 	}
 
 	responseBody, _ := json.Marshal(RPCResponse{
@@ -143,53 +159,317 @@ func (l *Listener) processRequest(ctx context.Context, pattern string, data json
     }
     }
 
-	log.Printf("[DEBUG] Procesando request. Pattern: %s, UserID: %s", pattern, userID)
+    // Los carritos de invitado se identifican por guest_id cuando no hay usuario autenticado
+    var guestID string
+    if id, ok := temp["guest_id"].(string); ok {
+        guestID = id
+    }
+    cartID := userID
+    if cartID == "" && guestID != "" {
+        cartID = models.GuestCartID(guestID)
+    }
+
+	log.Printf("[DEBUG] Procesando request. Pattern: %s, UserID: %s, CartID: %s", pattern, userID, cartID)
     
     switch pattern {
     case "adjust_item_quantity":
 		var payload struct {
             ProductID string `json:"product_id"`
+            Size string `json:"size"`
+            Color string `json:"color"`
             QuantityChange int `json:"quantity"`
         }
 		if err := json.Unmarshal(data, &payload); err != nil {
             log.Printf("Deserialización de adjust_item_quantity fallida: %v", err)
 			return nil, fmt.Errorf("datos de entrada inválidos para adjust_item_quantity")
         }
-        return l.Service.UpdateItemQuantity(ctx, userID, payload.ProductID, payload.QuantityChange)
+        return l.Service.UpdateItemQuantity(ctx, cartID, payload.ProductID, models.Variant{Size: payload.Size, Color: payload.Color}, payload.QuantityChange)
+
+    case "set_cart_items":
+        var payload struct {
+            Mode string `json:"mode"`
+            Items []struct {
+                ProductID string `json:"product_id"`
+                Size string `json:"size"`
+                Color string `json:"color"`
+                Quantity int `json:"quantity"`
+            } `json:"items"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para set_cart_items")
+        }
+        lines := make([]models.CartItem, len(payload.Items))
+        for i, item := range payload.Items {
+            lines[i] = models.CartItem{
+                ProductID: item.ProductID,
+                Variant: models.Variant{Size: item.Size, Color: item.Color},
+                Quantity: item.Quantity,
+            }
+        }
+        return l.Service.SetCartItems(ctx, cartID, payload.Mode, lines)
 
     case "get_cart_by_user":
-        return l.Service.GetCartWithPrices(ctx, userID)
+        return l.Service.GetCartWithPrices(ctx, cartID)
+
+    case "merge_guest_cart":
+        return l.Service.MergeGuestCart(ctx, userID, guestID)
 
     case "process_checkout":
         var payload struct {
-            ShippingAddress string `json:"shippingAddress"`
+            ShippingAddress models.Address `json:"shipping_address"`
+            IdempotencyKey string `json:"idempotency_key"`
+        }
+
+        if err := json.Unmarshal(data, &payload); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para process_checkout: se requiere shipping_address con region, comuna, street y number")
         }
+        return l.OrderService.ProcesarCompra(ctx, userID, payload.ShippingAddress, payload.IdempotencyKey)
 
+    case "quote_shipping":
+        var payload struct {
+            ShippingAddress models.Address `json:"shipping_address"`
+        }
         if err := json.Unmarshal(data, &payload); err != nil {
-            return nil, fmt.Errorf("datos de entrada inválidos para process_checkout")
+            return nil, fmt.Errorf("datos de entrada inválidos para quote_shipping")
         }
-        return l.OrderService.ProcesarCompra(ctx, userID, payload.ShippingAddress)
+        return l.OrderService.Shipping.QuoteCart(ctx, cartID, payload.ShippingAddress)
 
-    case "get_user_orders":
+    // get_user_orders devuelve el arreglo completo como siempre; get_user_orders_page pagina con cursor
+    case "get_user_orders", "get_user_orders_page":
         userIDUint64, err := strconv.ParseUint(userID, 10, 64)
 		if err != nil {
 			return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
 		}
 		userIDUint := uint(userIDUint64)
 
-		return l.OrderService.GetUserOrders(ctx, userIDUint)
+        var query models.OrderQuery
+        if err := json.Unmarshal(data, &query); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para %s: %w", pattern, err)
+        }
+        if pattern == "get_user_orders" {
+            return l.OrderService.ListUserOrders(ctx, userIDUint, query)
+        }
+		return l.OrderService.GetUserOrders(ctx, userIDUint, query)
     
 	case "remove_item_from_cart":
         var payload struct {
             ProductID string `json:"product_id"`
+            Size string `json:"size"`
+            Color string `json:"color"`
+            BundleID string `json:"bundle_id"`
         }
         if err := json.Unmarshal(data, &payload); err != nil {
             return nil, fmt.Errorf("datos de entrada inválidos para remove_item_from_cart")
         }
-        return l.Service.RemoveItemFromCart(ctx, userID, payload.ProductID)
+        if payload.BundleID != "" {
+            return l.Service.RemoveBundleFromCart(ctx, cartID, payload.BundleID)
+        }
+        return l.Service.RemoveItemFromCart(ctx, cartID, payload.ProductID, models.Variant{Size: payload.Size, Color: payload.Color})
+
+	case "get_all_orders", "get_all_orders_page":
+        var query models.OrderQuery
+        if err := json.Unmarshal(data, &query); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para %s: %w", pattern, err)
+        }
+        if pattern == "get_all_orders" {
+            return l.OrderService.ListAllOrders(ctx, query)
+        }
+        return l.OrderService.GetAllOrders(ctx, query)
+
+    case "export_orders":
+        var request service.OrderExportRequest
+        if err := json.Unmarshal(data, &request); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para export_orders: %w", err)
+        }
+        return l.OrderService.ExportOrders(ctx, request)
+
+    case "get_sales_summary", "get_top_products", "get_conversion_stats":
+        var query models.AnalyticsQuery
+        if err := json.Unmarshal(data, &query); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para %s: %w", pattern, err)
+        }
+        switch pattern {
+        case "get_sales_summary":
+            return l.AnalyticsService.GetSalesSummary(ctx, query)
+        case "get_top_products":
+            return l.AnalyticsService.GetTopProducts(ctx, query)
+        default:
+            return l.AnalyticsService.GetConversionStats(ctx, query)
+        }
+
+    case "cancel_order":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+            Reason string `json:"reason"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para cancel_order")
+        }
+        return l.OrderService.CancelOrder(ctx, userID, payload.OrderID, payload.Reason)
+
+    case "refund_order":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+            Amount int64 `json:"amount"` // 0 = reembolsar el saldo completo
+            Reason string `json:"reason"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para refund_order")
+        }
+        return l.OrderService.RefundOrder(ctx, payload.OrderID, payload.Amount, payload.Reason, models.StatusSourceAdmin)
+
+    case "get_order_refunds":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para get_order_refunds")
+        }
+        return l.OrderService.GetOrderRefunds(ctx, payload.OrderID)
+
+    case "create_shipment":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+            Carrier string `json:"carrier"`
+            TrackingNumber string `json:"tracking_number"`
+            Items []models.ShipmentItem `json:"items"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para create_shipment")
+        }
+        return l.ShipmentService.CreateShipment(ctx, payload.OrderID, payload.Carrier, payload.TrackingNumber, payload.Items)
+
+    case "update_shipment":
+        var payload struct {
+            ShipmentID uint `json:"shipment_id"`
+            service.ShipmentUpdate
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.ShipmentID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para update_shipment")
+        }
+        return l.ShipmentService.UpdateShipment(ctx, payload.ShipmentID, payload.ShipmentUpdate)
 
-	case "get_all_orders":
-        return l.OrderService.GetAllOrders(ctx)
+    case "get_order_shipments":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para get_order_shipments")
+        }
+        return l.ShipmentService.GetOrderShipments(ctx, userID, payload.OrderID)
+
+    case "request_return":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+            Resolution string `json:"resolution"`
+            Reason string `json:"reason"`
+            Items []models.ReturnItem `json:"items"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para request_return")
+        }
+        return l.ReturnService.RequestReturn(ctx, userID, payload.OrderID, payload.Resolution, payload.Reason, payload.Items)
+
+    case "get_user_returns":
+        return l.ReturnService.GetUserReturns(ctx, userID)
+
+    case "get_order_returns":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para get_order_returns")
+        }
+        return l.ReturnService.GetOrderReturns(ctx, payload.OrderID)
+
+    case "approve_return", "reject_return", "receive_return":
+        var payload struct {
+            ReturnID uint `json:"return_id"`
+            Note string `json:"note"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.ReturnID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para %s", pattern)
+        }
+        switch pattern {
+        case "approve_return":
+            return l.ReturnService.ApproveReturn(ctx, payload.ReturnID, payload.Note)
+        case "reject_return":
+            return l.ReturnService.RejectReturn(ctx, payload.ReturnID, payload.Note)
+        default:
+            return l.ReturnService.ReceiveReturn(ctx, payload.ReturnID)
+        }
+
+    case "get_order_receipt":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+            Format string `json:"format"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para get_order_receipt")
+        }
+        return l.OrderService.Receipts.GetOrderReceipt(ctx, userID, payload.OrderID, payload.Format)
+
+    case "get_order_history":
+        var payload struct {
+            OrderID uint `json:"order_id"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil || payload.OrderID == 0 {
+            return nil, fmt.Errorf("datos de entrada inválidos para get_order_history")
+        }
+        return l.OrderService.GetOrderHistory(ctx, payload.OrderID)
+
+    case "apply_coupon":
+        var payload struct {
+            Code string `json:"code"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para apply_coupon")
+        }
+        return l.Service.ApplyCoupon(ctx, cartID, payload.Code)
+
+    case "add_outfit_to_cart":
+        var payload struct {
+            Items []struct {
+                ProductID string `json:"product_id"`
+                Size string `json:"size"`
+                Color string `json:"color"`
+            } `json:"items"`
+            Quantity int `json:"quantity"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para add_outfit_to_cart")
+        }
+        pieces := make([]models.CartItem, len(payload.Items))
+        for i, item := range payload.Items {
+            pieces[i] = models.CartItem{
+                ProductID: item.ProductID,
+                Variant: models.Variant{Size: item.Size, Color: item.Color},
+            }
+        }
+        return l.Service.AddOutfitToCart(ctx, cartID, pieces, payload.Quantity)
+
+    case "acknowledge_price_changes":
+        return l.Service.AcknowledgePriceChanges(ctx, cartID)
+
+    case "remove_coupon":
+        return l.Service.RemoveCoupon(ctx, cartID)
+
+    case "set_product_purchase_limit":
+        var payload struct {
+            ProductID string `json:"product_id"`
+            MaxPerUser int `json:"max_per_user"`
+        }
+        if err := json.Unmarshal(data, &payload); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para set_product_purchase_limit")
+        }
+        return l.Service.Limits.SetProductLimit(ctx, payload.ProductID, payload.MaxPerUser)
+
+    case "create_coupon":
+        var payload models.Coupon
+        if err := json.Unmarshal(data, &payload); err != nil {
+            return nil, fmt.Errorf("datos de entrada inválidos para create_coupon")
+        }
+        return l.Service.Promotions.CreateCoupon(ctx, &payload)
 
     default:
         return nil, fmt.Errorf("patrón RPC no reconocido: %s", pattern)
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/messaging"
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+)
+
+const CartAbandonedRoutingKey = "cart.abandoned"
+
+type AbandonedCartEvent struct {
+	UserID      string                     `json:"user_id"`
+	Items       []product.CartItemSnapshot `json:"items"`
+	Value       int                        `json:"value"`
+	LastUpdated time.Time                  `json:"last_updated"`
+	DetectedAt  time.Time                  `json:"detected_at"`
+}
+
+// AbandonedCartScanner busca carritos sin cambios por más de Threshold y publica cart.abandoned.
+// Los carritos de invitado se omiten porque marketing no tiene a quién contactar.
+type AbandonedCartScanner struct {
+	CartService *CartService
+	Publisher   *messaging.CartPublisher
+	Threshold   time.Duration
+}
+
+func NewAbandonedCartScanner(cartService *CartService, publisher *messaging.CartPublisher, threshold time.Duration) *AbandonedCartScanner {
+	return &AbandonedCartScanner{
+		CartService: cartService,
+		Publisher:   publisher,
+		Threshold:   threshold,
+	}
+}
+
+func (s *AbandonedCartScanner) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Scan(ctx)
+			}
+		}
+	}()
+	log.Printf("Detector de carritos abandonados iniciado (umbral %s, cada %s)", s.Threshold, interval)
+}
+
+func (s *AbandonedCartScanner) Scan(ctx context.Context) {
+	before := time.Now().Add(-s.Threshold)
+	repo := s.CartService.Repo
+
+	err := repo.ScanIdle(ctx, before, func(cart *models.Cart) error {
+		if models.IsGuestCartID(cart.UserID) {
+			return nil
+		}
+
+		// La marca se toma antes de publicar para que dos instancias no avisen el mismo carrito;
+		// si el aviso falla se borra y el siguiente barrido lo reintenta
+		isNew, err := repo.MarkAbandonedNotified(ctx, cart)
+		if err != nil {
+			log.Printf("Error marcando carrito abandonado %s: %v", cart.UserID, err)
+			return nil
+		}
+		if !isNew {
+			return nil
+		}
+
+		if err := s.notify(ctx, cart); err != nil {
+			log.Printf("Error avisando carrito abandonado %s: %v", cart.UserID, err)
+			if clearErr := repo.ClearAbandonedNotified(ctx, cart); clearErr != nil {
+				log.Printf("Error borrando la marca del carrito abandonado %s, no se reintentará: %v", cart.UserID, clearErr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error recorriendo carritos en Redis: %v", err)
+	}
+}
+
+// notify calcula el carrito con precios vigentes y publica cart.abandoned
+func (s *AbandonedCartScanner) notify(ctx context.Context, cart *models.Cart) error {
+	priced, err := s.CartService.GetCartWithPrices(ctx, cart.UserID)
+	if err != nil {
+		return err
+	}
+
+	event := AbandonedCartEvent{
+		UserID:      cart.UserID,
+		Items:       priced.Items,
+		Value:       priced.TotalPrice,
+		LastUpdated: cart.LastUpdated,
+		DetectedAt:  time.Now(),
+	}
+	return s.Publisher.Publish(ctx, CartAbandonedRoutingKey, event)
+}
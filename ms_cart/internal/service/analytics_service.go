@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// AnalyticsService expone los reportes de ventas del panel de administración
+type AnalyticsService struct {
+	Repo repository.AnalyticsRepository
+}
+
+func NewAnalyticsService(repo repository.AnalyticsRepository) *AnalyticsService {
+	return &AnalyticsService{Repo: repo}
+}
+
+// GetSalesSummary agrupa ingresos, órdenes y ticket promedio por día, semana o mes
+func (s *AnalyticsService) GetSalesSummary(ctx context.Context, query models.AnalyticsQuery) (*models.SalesSummary, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+	buckets, err := s.Repo.SalesByPeriod(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Postgres devuelve el inicio del período como hora local sin zona; se le asigna la zona pedida
+	location, _ := time.LoadLocation(query.Timezone)
+	summary := &models.SalesSummary{
+		Granularity: query.Granularity,
+		Timezone:    query.Timezone,
+		Buckets:     buckets,
+	}
+	for i := range buckets {
+		p := buckets[i].Period
+		buckets[i].Period = time.Date(p.Year(), p.Month(), p.Day(), 0, 0, 0, 0, location)
+		buckets[i].Complete()
+
+		summary.Totals.Orders += buckets[i].Orders
+		summary.Totals.Revenue += buckets[i].Revenue
+		summary.Totals.Refunded += buckets[i].Refunded
+	}
+	summary.Totals.Complete()
+	return summary, nil
+}
+
+// GetTopProducts devuelve los productos más vendidos por unidades o por ingresos
+func (s *AnalyticsService) GetTopProducts(ctx context.Context, query models.AnalyticsQuery) ([]models.ProductSales, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+	return s.Repo.TopProducts(ctx, query)
+}
+
+// GetConversionStats mide cuántas órdenes creadas llegaron a pagarse y cuántas fallaron por stock
+func (s *AnalyticsService) GetConversionStats(ctx context.Context, query models.AnalyticsQuery) (*models.ConversionStats, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+	stats, err := s.Repo.Conversion(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	stats.Complete()
+	return stats, nil
+}
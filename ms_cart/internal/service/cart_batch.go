@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+)
+
+const (
+	SetCartModeReplace = "replace"
+	SetCartModePatch   = "patch"
+)
+
+// SetCartItems reemplaza (replace) o ajusta (patch) varias líneas sueltas en una sola operación.
+// En patch una cantidad 0 elimina la línea. El stock se valida por producto con una única llamada a
+// ms_products sobre el carrito resultante; lo disponible de cada producto se reparte entre sus líneas en
+// el orden de la petición y cada línea se acepta, se recorta o se rechaza (y sale del carrito).
+func (s *CartService) SetCartItems(ctx context.Context, userID string, mode string, lines []models.CartItem) (*models.SetCartItemsResult, error) {
+	if mode == "" {
+		mode = SetCartModeReplace
+	}
+	if mode != SetCartModeReplace && mode != SetCartModePatch {
+		return nil, fmt.Errorf("modo inválido para set_cart_items: %s", mode)
+	}
+
+	// Si una línea se repite en la petición, gana la última
+	requested := make([]models.CartItem, 0, len(lines))
+	positions := make(map[string]int, len(lines))
+	for _, line := range lines {
+		line.Variant = line.Variant.Normalize()
+		line.BundleID = ""
+		if pos, ok := positions[line.LineKey()]; ok {
+			requested[pos] = line
+			continue
+		}
+		positions[line.LineKey()] = len(requested)
+		requested = append(requested, line)
+	}
+
+	result := &models.SetCartItemsResult{}
+
+	cart, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		results := make([]models.CartLineResult, len(requested))
+		finalQuantities := make(map[string]int, len(requested))
+
+		var toValidate []models.CartItem
+		var validateIdx []int
+		clampedByLimit := make(map[int]bool)
+		for i, line := range requested {
+			results[i] = models.CartLineResult{
+				ProductID: line.ProductID,
+				Variant:   line.Variant,
+				Requested: line.Quantity,
+			}
+			switch {
+			case line.ProductID == "":
+				results[i].Status = models.LineRejected
+				results[i].Reason = "product_id es obligatorio"
+			case line.Quantity < 0:
+				results[i].Status = models.LineRejected
+				results[i].Reason = "la cantidad no puede ser negativa"
+			case line.Quantity == 0:
+				results[i].Status = models.LineAccepted
+				finalQuantities[line.LineKey()] = 0
+			default:
+				if s.Limits != nil && s.Limits.Limits.MaxLineQuantity > 0 && line.Quantity > s.Limits.Limits.MaxLineQuantity {
+					line.Quantity = s.Limits.Limits.MaxLineQuantity
+					clampedByLimit[i] = true
+				}
+				toValidate = append(toValidate, line)
+				validateIdx = append(validateIdx, i)
+			}
+		}
+
+		// En patch las líneas que la petición no toca (incluidas las prendas de outfits) siguen en el carrito
+		// y consumen el mismo stock del producto que las líneas pedidas
+		touched := make(map[string]bool, len(requested))
+		for key := range finalQuantities {
+			touched[key] = true
+		}
+		for _, line := range toValidate {
+			touched[line.LineKey()] = true
+		}
+		var kept []models.CartItem
+		if mode == SetCartModePatch {
+			for _, item := range cart.Items {
+				if !touched[item.LineKey()] {
+					kept = append(kept, item)
+				}
+			}
+		}
+
+		if len(toValidate) > 0 {
+			// Se valida el carrito como queda tras el cambio, agrupado por producto
+			stockInputs := productStockInputs(append(append([]models.CartItem{}, kept...), toValidate...))
+			validation, rpcErr := s.ProductClient.ValidateStock(ctx, stockInputs)
+			if rpcErr != nil {
+				return fmt.Errorf("fallo RPC al validar stock con ms_products: %w", rpcErr)
+			}
+			detailed := len(validation.Items) == len(stockInputs)
+
+			// remaining es lo que queda de cada producto para las líneas pedidas, que se reparte en el
+			// orden de la petición
+			productIdx := make(map[string]int, len(stockInputs))
+			remaining := make(map[string]int, len(stockInputs))
+			for k, input := range stockInputs {
+				productIdx[input.ProductID] = k
+				if detailed {
+					remaining[input.ProductID] = validation.Items[k].Available
+				}
+			}
+			for _, item := range kept {
+				remaining[item.ProductID] -= item.Quantity
+			}
+
+			for j, line := range toValidate {
+				i := validateIdx[j]
+				var stock product.StockLineResult
+				if detailed {
+					stock = validation.Items[productIdx[line.ProductID]]
+				}
+				switch {
+				case validation.Valid || stock.Valid:
+					results[i].Status = models.LineAccepted
+					results[i].Quantity = line.Quantity
+				case !detailed:
+					// ms_products sin detalle por producto: no se puede saber cuál falló
+					results[i].Status = models.LineRejected
+					results[i].Reason = validation.Message
+					continue
+				case !stock.Found:
+					// Una línea rechazada sale del carrito para no quedar sobre el stock del producto
+					results[i].Status = models.LineRejected
+					results[i].Reason = "producto no encontrado"
+					finalQuantities[line.LineKey()] = 0
+					continue
+				case remaining[line.ProductID] <= 0:
+					results[i].Status = models.LineRejected
+					results[i].Reason = "sin stock disponible"
+					finalQuantities[line.LineKey()] = 0
+					continue
+				case remaining[line.ProductID] >= line.Quantity:
+					results[i].Status = models.LineAccepted
+					results[i].Quantity = line.Quantity
+				default:
+					results[i].Status = models.LineClamped
+					results[i].Quantity = remaining[line.ProductID]
+					results[i].Reason = fmt.Sprintf("solo hay %d unidades disponibles", remaining[line.ProductID])
+				}
+				remaining[line.ProductID] -= results[i].Quantity
+				if clampedByLimit[i] && results[i].Status == models.LineAccepted {
+					results[i].Status = models.LineClamped
+					results[i].Reason = fmt.Sprintf("máximo %d unidades por producto", s.Limits.Limits.MaxLineQuantity)
+				}
+				finalQuantities[line.LineKey()] = results[i].Quantity
+			}
+		}
+
+		var newItems []models.CartItem
+		var needPrice []models.CartItem
+		existing := make(map[string]models.CartItem, len(cart.Items))
+		for _, item := range cart.Items {
+			existing[item.LineKey()] = item
+			if mode == SetCartModeReplace {
+				continue
+			}
+			if qty, ok := finalQuantities[item.LineKey()]; ok {
+				if qty > 0 {
+					item.Quantity = qty
+					newItems = append(newItems, item)
+				}
+				continue
+			}
+			newItems = append(newItems, item)
+		}
+
+		for _, line := range requested {
+			qty, ok := finalQuantities[line.LineKey()]
+			if !ok || qty == 0 {
+				continue
+			}
+			prev, existed := existing[line.LineKey()]
+			if existed && mode == SetCartModePatch {
+				continue // ya actualizada arriba
+			}
+			item := models.CartItem{
+				ProductID: line.ProductID,
+				Variant:   line.Variant,
+				Quantity:  qty,
+				UnitPrice: prev.UnitPrice,
+			}
+			if item.UnitPrice == 0 {
+				needPrice = append(needPrice, item)
+			}
+			newItems = append(newItems, item)
+		}
+
+		if len(needPrice) > 0 {
+			prices, err := s.currentUnitPrices(ctx, needPrice)
+			if err != nil {
+				return err
+			}
+			for i := range newItems {
+				if newItems[i].UnitPrice == 0 {
+					newItems[i].UnitPrice = prices[newItems[i].LineKey()]
+				}
+			}
+		}
+
+		if newItems == nil {
+			newItems = []models.CartItem{}
+		}
+		if err := s.checkLimits(ctx, userID, newItems); err != nil {
+			return err
+		}
+		cart.Items = newItems
+		result.Lines = results
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Cart = cart
+	return result, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// memoryCartRepository guarda los carritos en memoria, sin control de versión
+type memoryCartRepository struct {
+	repository.CartRepository
+	carts map[string]*models.Cart
+}
+
+func newMemoryCartRepository(carts ...*models.Cart) *memoryCartRepository {
+	r := &memoryCartRepository{carts: make(map[string]*models.Cart)}
+	for _, cart := range carts {
+		r.carts[cart.UserID] = cart
+	}
+	return r
+}
+
+func (r *memoryCartRepository) FindByUserID(ctx context.Context, userID string) (*models.Cart, error) {
+	cart, ok := r.carts[userID]
+	if !ok {
+		return &models.Cart{UserID: userID, Items: []models.CartItem{}}, nil
+	}
+	copied := *cart
+	copied.Items = append([]models.CartItem{}, cart.Items...)
+	return &copied, nil
+}
+
+func (r *memoryCartRepository) Save(ctx context.Context, cart *models.Cart) error {
+	r.carts[cart.UserID] = cart
+	return nil
+}
+
+// stockClient responde con el stock por producto de stock y un precio fijo por unidad
+type stockClient struct {
+	product.ClientInterface
+	stock     map[string]int
+	unitPrice int
+}
+
+func (c *stockClient) ValidateStock(ctx context.Context, items []product.ProductInput) (*product.StockValidationOutput, error) {
+	output := &product.StockValidationOutput{Valid: true}
+	for _, item := range items {
+		available, found := c.stock[item.ProductID]
+		line := product.StockLineResult{
+			ProductID: item.ProductID,
+			Requested: item.Quantity,
+			Available: available,
+			Found:     found,
+			Valid:     found && item.Quantity <= available,
+		}
+		if !line.Valid {
+			output.Valid = false
+			output.Message = "stock insuficiente para " + item.ProductID
+		}
+		output.Items = append(output.Items, line)
+	}
+	return output, nil
+}
+
+func (c *stockClient) CalculateCart(ctx context.Context, items []product.ProductInput) (*product.CartCalculationOutput, error) {
+	output := &product.CartCalculationOutput{}
+	for _, item := range items {
+		output.Items = append(output.Items, product.CartItemSnapshot{
+			ProductID: item.ProductID,
+			UnitPrice: c.unitPrice,
+			Quantity:  item.Quantity,
+			Subtotal:  c.unitPrice * item.Quantity,
+		})
+		output.TotalPrice += c.unitPrice * item.Quantity
+	}
+	return output, nil
+}
+
+func cartQuantities(cart *models.Cart) map[string]int {
+	quantities := make(map[string]int, len(cart.Items))
+	for _, item := range cart.Items {
+		quantities[item.LineKey()] = item.Quantity
+	}
+	return quantities
+}
+
+func TestSetCartItemsValidatesStockPerProduct(t *testing.T) {
+	s := &CartService{
+		Repo:          newMemoryCartRepository(),
+		ProductClient: &stockClient{stock: map[string]int{"p1": 4, "p2": 10}, unitPrice: 1000},
+	}
+
+	// Cada variante cabe sola en el stock (4) pero juntas lo superan
+	result, err := s.SetCartItems(context.Background(), "u1", SetCartModeReplace, []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 3},
+		{ProductID: "p1", Variant: models.Variant{Size: "M"}, Quantity: 3},
+		{ProductID: "p2", Quantity: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		status   string
+		quantity int
+	}{
+		{models.LineAccepted, 3},
+		{models.LineClamped, 1},
+		{models.LineAccepted, 2},
+	}
+	for i, w := range want {
+		if got := result.Lines[i]; got.Status != w.status || got.Quantity != w.quantity {
+			t.Errorf("línea %d: got %s x%d, want %s x%d", i, got.Status, got.Quantity, w.status, w.quantity)
+		}
+	}
+
+	quantities := cartQuantities(result.Cart)
+	if quantities["p1|S|"]+quantities["p1|M|"] != 4 {
+		t.Errorf("el carrito supera el stock de p1: %v", quantities)
+	}
+}
+
+func TestSetCartItemsPatchCountsUntouchedLines(t *testing.T) {
+	repo := newMemoryCartRepository(&models.Cart{UserID: "u1", Items: []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 1, UnitPrice: 1000},
+		{ProductID: "p1", Variant: models.Variant{Size: "M"}, Quantity: 2, UnitPrice: 1000, BundleID: "b1"},
+		{ProductID: "p2", Variant: models.Variant{Size: "M"}, Quantity: 2, UnitPrice: 1000, BundleID: "b1"},
+		{ProductID: "p3", Quantity: 1, UnitPrice: 1000},
+	}})
+	s := &CartService{
+		Repo:          repo,
+		ProductClient: &stockClient{stock: map[string]int{"p1": 5, "p2": 10, "p3": 1}, unitPrice: 1000},
+	}
+
+	// Quedan 2 unidades de p1 (5 menos la línea suelta y la prenda del outfit) y ninguna de p3
+	result, err := s.SetCartItems(context.Background(), "u1", SetCartModePatch, []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "L"}, Quantity: 3},
+		{ProductID: "p3", Variant: models.Variant{Size: "S"}, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := result.Lines[0]; got.Status != models.LineClamped || got.Quantity != 2 {
+		t.Errorf("p1 L: got %s x%d, want CLAMPED x2", got.Status, got.Quantity)
+	}
+	if got := result.Lines[1]; got.Status != models.LineRejected {
+		t.Errorf("p3 S: got %s, want REJECTED", got.Status)
+	}
+
+	quantities := cartQuantities(result.Cart)
+	if quantities["p1|S|"] != 1 || quantities["p1|M||b1"] != 2 || quantities["p1|L|"] != 2 || quantities["p3||"] != 1 {
+		t.Errorf("carrito resultante: %v", quantities)
+	}
+	if _, ok := quantities["p3|S|"]; ok {
+		t.Error("la línea rechazada no debe quedar en el carrito")
+	}
+}
+
+func TestSetCartItemsPatchReplacesExistingLine(t *testing.T) {
+	repo := newMemoryCartRepository(&models.Cart{UserID: "u1", Items: []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 3, UnitPrice: 900},
+	}})
+	s := &CartService{
+		Repo:          repo,
+		ProductClient: &stockClient{stock: map[string]int{"p1": 4}, unitPrice: 1000},
+	}
+
+	// La cantidad nueva reemplaza a la anterior, así que no se cuenta dos veces
+	result, err := s.SetCartItems(context.Background(), "u1", SetCartModePatch, []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "s"}, Quantity: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Lines[0]; got.Status != models.LineAccepted || got.Quantity != 4 {
+		t.Errorf("got %s x%d, want ACCEPTED x4", got.Status, got.Quantity)
+	}
+	if item := result.Cart.Items[0]; item.Quantity != 4 || item.UnitPrice != 900 {
+		t.Errorf("la línea conserva su precio capturado: %+v", item)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+)
+
+// outfitPriceClient responde calculate_outfit_price con un precio fijo por combinación de productos
+type outfitPriceClient struct {
+	product.ClientInterface
+	prices map[string]int
+	calls  [][]string
+}
+
+func (c *outfitPriceClient) CalculateOutfitPrice(ctx context.Context, productIDs []string) (*product.OutfitCalculationOutput, error) {
+	c.calls = append(c.calls, productIDs)
+	key := ""
+	for _, id := range productIDs {
+		key += id + ","
+	}
+	return &product.OutfitCalculationOutput{TotalPrice: c.prices[key]}, nil
+}
+
+func TestApplyBundlePricing(t *testing.T) {
+	client := &outfitPriceClient{prices: map[string]int{
+		"p1,p2,": 25000, // más barato que las prendas sueltas (30000)
+		"p3,":    99999, // más caro: se usa la suma de las prendas
+	}}
+	calculation := &product.CartCalculationOutput{Items: []product.CartItemSnapshot{
+		{ProductID: "p1", BundleID: "b1", UnitPrice: 10000, Quantity: 2},
+		{ProductID: "suelto", UnitPrice: 5000, Quantity: 1},
+		{ProductID: "p2", BundleID: "b1", UnitPrice: 20000, Quantity: 2},
+		{ProductID: "p3", BundleID: "b2", UnitPrice: 8000, Quantity: 1},
+	}}
+
+	savings, err := applyBundlePricing(context.Background(), client, calculation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if savings != 10000 {
+		t.Errorf("ahorro: got %d, want 10000 (5000 x 2 outfits)", savings)
+	}
+
+	want := []product.BundleSummary{
+		{BundleID: "b1", ProductIDs: []string{"p1", "p2"}, Quantity: 2, UnitPrice: 25000, Subtotal: 50000},
+		{BundleID: "b2", ProductIDs: []string{"p3"}, Quantity: 1, UnitPrice: 8000, Subtotal: 8000},
+	}
+	if !reflect.DeepEqual(calculation.Bundles, want) {
+		t.Errorf("bundles: got %+v, want %+v", calculation.Bundles, want)
+	}
+	if len(client.calls) != 2 {
+		t.Errorf("se esperaba una llamada por outfit, got %d", len(client.calls))
+	}
+}
+
+func TestApplyBundlePricingWithoutBundles(t *testing.T) {
+	calculation := &product.CartCalculationOutput{Items: []product.CartItemSnapshot{{ProductID: "p1", UnitPrice: 1000, Quantity: 1}}}
+
+	savings, err := applyBundlePricing(context.Background(), &outfitPriceClient{}, calculation)
+	if err != nil || savings != 0 || len(calculation.Bundles) != 0 {
+		t.Fatalf("got savings=%d bundles=%v err=%v", savings, calculation.Bundles, err)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+type memoryCouponRepository struct {
+	repository.CouponRepository
+	coupons map[string]*models.Coupon
+}
+
+func (r *memoryCouponRepository) FindByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	coupon, ok := r.coupons[code]
+	if !ok {
+		return nil, errors.New("cupón no encontrado")
+	}
+	return coupon, nil
+}
+
+// outfitStockClient precia cualquier outfit a outfitPrice
+type outfitStockClient struct {
+	*stockClient
+	outfitPrice int
+}
+
+func (c *outfitStockClient) CalculateOutfitPrice(ctx context.Context, productIDs []string) (*product.OutfitCalculationOutput, error) {
+	return &product.OutfitCalculationOutput{TotalPrice: c.outfitPrice}, nil
+}
+
+func TestApplyCouponChecksMinSpendAfterOutfitSavings(t *testing.T) {
+	// Dos prendas de 10000 como outfit de 15000: el total a pagar antes del cupón es 15000
+	cart := &models.Cart{UserID: "7", Items: []models.CartItem{
+		{ProductID: "p1", Quantity: 1, UnitPrice: 10000, BundleID: "b1"},
+		{ProductID: "p2", Quantity: 1, UnitPrice: 10000, BundleID: "b1"},
+	}}
+	s := &CartService{
+		Repo:          newMemoryCartRepository(cart),
+		ProductClient: &outfitStockClient{stockClient: &stockClient{unitPrice: 10000}, outfitPrice: 15000},
+		Promotions: &PromotionService{Repo: &memoryCouponRepository{coupons: map[string]*models.Coupon{
+			"MIN18": {Code: "MIN18", Type: models.CouponTypeFixed, Value: 1000, MinSpend: 18000, Active: true},
+			"MIN15": {Code: "MIN15", Type: models.CouponTypeFixed, Value: 1000, MinSpend: 15000, Active: true},
+		}}},
+	}
+
+	if _, err := s.ApplyCoupon(context.Background(), "7", "MIN18"); !errors.Is(err, ErrCouponMinSpend) {
+		t.Fatalf("MIN18: se esperaba ErrCouponMinSpend, got %v", err)
+	}
+
+	calculation, err := s.ApplyCoupon(context.Background(), "7", "MIN15")
+	if err != nil {
+		t.Fatalf("MIN15: %v", err)
+	}
+	if calculation.CouponError != "" || calculation.CouponCode != "MIN15" {
+		t.Errorf("el cupón aceptado debe aplicarse al ver el carrito: %+v", calculation)
+	}
+	if calculation.Subtotal != 20000 || calculation.TotalPrice != 14000 {
+		t.Errorf("totales: subtotal %d total %d, want 20000 y 14000", calculation.Subtotal, calculation.TotalPrice)
+	}
+}
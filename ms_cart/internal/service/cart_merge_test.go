@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+func TestMergeGuestCartReportsDroppedGuestQuantities(t *testing.T) {
+	userCart := &models.Cart{UserID: "7", Items: []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 2, UnitPrice: 1000},
+		{ProductID: "p3", Quantity: 1, UnitPrice: 1000},
+		{ProductID: "p4", Quantity: 3, UnitPrice: 1000},
+	}}
+	guestCart := &models.Cart{UserID: models.GuestCartID("g1"), Items: []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "M"}, Quantity: 2, UnitPrice: 1000},
+		{ProductID: "p2", Quantity: 1, UnitPrice: 1000},
+		{ProductID: "p3", Quantity: 2, UnitPrice: 1000},
+	}}
+	repo := newMemoryCartRepository(userCart, guestCart)
+	s := &CartService{
+		Repo: repo,
+		// p4 ya está sobre su stock solo con lo del usuario, pero el invitado no aporta a esa línea
+		ProductClient: &stockClient{stock: map[string]int{"p1": 3, "p2": 5, "p3": 2, "p4": 1}, unitPrice: 1000},
+	}
+
+	result, err := s.MergeGuestCart(context.Background(), "7", "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := make(map[string]int)
+	for _, item := range result.RejectedItems {
+		rejected[models.LineKey(item.ProductID, item.Variant)] = item.Quantity
+	}
+	want := map[string]int{"p1|M|": 2, "p3||": 2}
+	if !reflect.DeepEqual(rejected, want) {
+		t.Errorf("rechazados: got %v, want %v", rejected, want)
+	}
+
+	quantities := cartQuantities(result.Cart)
+	wantCart := map[string]int{"p1|S|": 2, "p2||": 1, "p3||": 1, "p4||": 3}
+	if !reflect.DeepEqual(quantities, wantCart) {
+		t.Errorf("carrito fusionado: got %v, want %v", quantities, wantCart)
+	}
+	if len(repo.carts[models.GuestCartID("g1")].Items) != 0 {
+		t.Error("el carrito de invitado debe quedar vacío")
+	}
+}
+
+func TestMergeGuestCartAcceptsVariantsWithinProductStock(t *testing.T) {
+	repo := newMemoryCartRepository(
+		&models.Cart{UserID: "7", Items: []models.CartItem{{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 1}}},
+		&models.Cart{UserID: models.GuestCartID("g1"), Items: []models.CartItem{
+			{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 1},
+			{ProductID: "p1", Variant: models.Variant{Size: "M"}, Quantity: 1},
+		}},
+	)
+	s := &CartService{Repo: repo, ProductClient: &stockClient{stock: map[string]int{"p1": 3}}}
+
+	result, err := s.MergeGuestCart(context.Background(), "7", "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.RejectedItems) != 0 {
+		t.Errorf("no debe rechazar nada: %+v", result.RejectedItems)
+	}
+	if quantities := cartQuantities(result.Cart); quantities["p1|S|"] != 2 || quantities["p1|M|"] != 1 {
+		t.Errorf("carrito fusionado: %v", quantities)
+	}
+}
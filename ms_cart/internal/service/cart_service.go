@@ -4,7 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
+	"strings"
+	"time"
 
 	"log"
 	"github.com/C0kke/FitFashion/ms_cart/internal/models"
@@ -15,101 +16,160 @@ import (
 type CartService struct {
 	Repo repository.CartRepository 
 	ProductClient product.ClientInterface
+	Promotions *PromotionService
+	Limits *LimitService
+	Tax *TaxCalculator
 }
 
-func NewCartService(repo repository.CartRepository, productClient product.ClientInterface) *CartService {
+func NewCartService(repo repository.CartRepository, productClient product.ClientInterface, promotions *PromotionService, limits *LimitService, tax *TaxCalculator) *CartService {
 	return &CartService{
 		Repo:          repo,
 		ProductClient: productClient,
+		Promotions:    promotions,
+		Limits:        limits,
+		Tax:           tax,
 	}
 }
 
-func (s *CartService) UpdateItemQuantity(ctx context.Context, userID string, productID string, quantityChange int) (*models.Cart, error) {
-	
-	cart, err := s.Repo.FindByUserID(ctx, userID)
-	log.Printf("[DEBUG-SVC] Carrito encontrado para %s. Items: %d", userID, len(cart.Items))
-	if err != nil {
-		return nil, fmt.Errorf("error al buscar carrito para actualización: %w", err)
+// checkLimits aplica los límites de compra configurados a las líneas resultantes
+func (s *CartService) checkLimits(ctx context.Context, userID string, items []models.CartItem) error {
+	if s.Limits == nil {
+		return nil
 	}
+	return s.Limits.CheckCart(ctx, userID, items)
+}
 
-	var newItems []models.CartItem
-	var currentQuantity = 0
-	var itemExists = false
+// maxCartSaveRetries limita los reintentos cuando otra operación modifica el mismo carrito
+const maxCartSaveRetries = 5
 
-	for _, item := range cart.Items {
-		if item.ProductID == productID {
-            currentQuantity = item.Quantity
-			itemExists = true
-			break
+// mutateCart lee el carrito, aplica mutate y lo guarda con control de versión,
+// reintentando desde una lectura fresca si otra operación lo modificó entre medio.
+func (s *CartService) mutateCart(ctx context.Context, userID string, mutate func(cart *models.Cart) error) (*models.Cart, error) {
+	for attempt := 1; attempt <= maxCartSaveRetries; attempt++ {
+		cart, err := s.Repo.FindByUserID(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("error al buscar carrito: %w", err)
 		}
-	}
-    log.Printf("[DEBUG-SVC] currentQuantity: %d, quantityChange: %d", currentQuantity, quantityChange)
-	targetQuantity := currentQuantity + quantityChange
-	
-	if !itemExists && quantityChange > 0 {
-		targetQuantity = quantityChange
-	}
-	log.Printf("[DEBUG-SVC] targetQuantity: %d, itemExists: %t", targetQuantity, itemExists)
-	if targetQuantity <= 0 && itemExists == false {
-		return cart, fmt.Errorf("el producto %s no existe en el carrito para esta operación", productID)
-	}
 
-	if targetQuantity > 0 {
-		itemsToValidate := []product.ProductInput{
-			{
-				ProductID: productID,
-				Quantity:  targetQuantity,
-			},
+		if err := mutate(cart); err != nil {
+			return cart, err
 		}
 
-		log.Printf("[DEBUG-SVC] Llamando a ms_products.ValidateStock para Producto: %s, Cantidad: %d", productID, targetQuantity)
-		validationResult, rpcErr := s.ProductClient.ValidateStock(ctx, itemsToValidate)
-		if rpcErr != nil {
-			log.Printf("[ERROR-CRITICO] FALLO RPC (ms_products): %v", rpcErr)
-			return nil, fmt.Errorf("fallo RPC al validar stock con ms_products: %w", rpcErr)
+		err = s.Repo.Save(ctx, cart)
+		if err == nil {
+			return cart, nil
+		}
+		if !errors.Is(err, repository.ErrCartVersionConflict) {
+			return nil, fmt.Errorf("error al guardar carrito: %w", err)
 		}
 
-		if !validationResult.Valid {
-			return nil, errors.New(validationResult.Message) 
+		log.Printf("[DEBUG-SVC] Conflicto de versión en carrito %s (intento %d/%d), reintentando", userID, attempt, maxCartSaveRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt*10) * time.Millisecond):
 		}
 	}
-    
-    var updated = false
-	newItems = []models.CartItem{} 
-	
-	for _, item := range cart.Items {
-		if item.ProductID == productID {
-			if targetQuantity > 0 {
-				item.Quantity = targetQuantity
-				newItems = append(newItems, item)
+
+	return nil, fmt.Errorf("no se pudo actualizar el carrito tras %d intentos: %w", maxCartSaveRetries, repository.ErrCartVersionConflict)
+}
+
+func (s *CartService) UpdateItemQuantity(ctx context.Context, userID string, productID string, variant models.Variant, quantityChange int) (*models.Cart, error) {
+	variant = variant.Normalize()
+	lineKey := models.LineKey(productID, variant)
+
+	cart, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		log.Printf("[DEBUG-SVC] Carrito encontrado para %s. Items: %d", userID, len(cart.Items))
+
+		var currentQuantity = 0
+		var itemExists = false
+		// Las demás variantes del producto consumen el mismo stock en ms_products
+		var otherVariantsQuantity = 0
+
+		for _, item := range cart.Items {
+			if item.LineKey() == lineKey {
+				currentQuantity = item.Quantity
+				itemExists = true
+			} else if item.ProductID == productID {
+				otherVariantsQuantity += item.Quantity
+			}
+		}
+		log.Printf("[DEBUG-SVC] currentQuantity: %d, quantityChange: %d", currentQuantity, quantityChange)
+		targetQuantity := currentQuantity + quantityChange
+
+		if !itemExists && quantityChange > 0 {
+			targetQuantity = quantityChange
+		}
+		log.Printf("[DEBUG-SVC] targetQuantity: %d, itemExists: %t", targetQuantity, itemExists)
+		if targetQuantity <= 0 && itemExists == false {
+			return fmt.Errorf("el producto %s no existe en el carrito para esta operación", productID)
+		}
+
+		if targetQuantity > 0 {
+			itemsToValidate := []product.ProductInput{
+				{
+					ProductID: productID,
+					Size:      variant.Size,
+					Color:     variant.Color,
+					Quantity:  targetQuantity + otherVariantsQuantity,
+				},
+			}
+
+			log.Printf("[DEBUG-SVC] Llamando a ms_products.ValidateStock para Producto: %s, Cantidad: %d", productID, targetQuantity+otherVariantsQuantity)
+			validationResult, rpcErr := s.ProductClient.ValidateStock(ctx, itemsToValidate)
+			if rpcErr != nil {
+				log.Printf("[ERROR-CRITICO] FALLO RPC (ms_products): %v", rpcErr)
+				return fmt.Errorf("fallo RPC al validar stock con ms_products: %w", rpcErr)
+			}
+
+			if !validationResult.Valid {
+				return errors.New(validationResult.Message)
+			}
+		}
+
+		var updated = false
+		newItems := []models.CartItem{}
+
+		for _, item := range cart.Items {
+			if item.LineKey() == lineKey {
+				if targetQuantity > 0 {
+					item.Quantity = targetQuantity
+					newItems = append(newItems, item)
+				}
 				updated = true
 			} else {
-				updated = true
+				newItems = append(newItems, item)
 			}
-		} else {
-			newItems = append(newItems, item)
 		}
-	}
-    if !updated && targetQuantity > 0 {
-		newItems = append(newItems, models.CartItem{
-			ProductID: productID,
-			Quantity:  targetQuantity,
-		})
-	}
-	
-	cart.Items = newItems
+		if !updated && targetQuantity > 0 {
+			newItem := models.CartItem{
+				ProductID: productID,
+				Variant:   variant,
+				Quantity:  targetQuantity,
+			}
+			prices, err := s.currentUnitPrices(ctx, []models.CartItem{newItem})
+			if err != nil {
+				return err
+			}
+			newItem.UnitPrice = prices[newItem.LineKey()]
+			newItems = append(newItems, newItem)
+		}
 
-	if len(cart.Items) == 0 {
-		err = s.Repo.DeleteByUserID(ctx, userID)
-	} else {
-		err = s.Repo.Save(ctx, cart)
-	}
+		// Solo los aumentos se limitan, para que siempre se pueda reducir un carrito que quedó sobre el límite
+		if quantityChange > 0 {
+			if err := s.checkLimits(ctx, userID, newItems); err != nil {
+				return err
+			}
+		}
 
+		cart.Items = newItems
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error al guardar carrito después de actualización de cantidad: %w", err)
+		return cart, err
 	}
 
-	log.Printf("[DEBUG-SVC] Operación completada. Guardando Carrito con ID: %s", userID) // Confirma que cart.ID tiene valor
+	log.Printf("[DEBUG-SVC] Operación completada. Guardando Carrito con ID: %s", userID)
 	return cart, nil
 }
 
@@ -125,51 +185,174 @@ func (s *CartService) GetCartWithPrices(ctx context.Context, userID string) (*pr
 	log.Printf("[DEBUG-SVC] Carrito encontrado para %s. Items: %d", userID, len(cart.Items))
 	if len(cart.Items) == 0 {
 		emptyCartOutput := &product.CartCalculationOutput{
-            UserID: strconv.Itoa(cart.UserID),
+            UserID: cart.UserID,
             TotalPrice:  0,
             Items: []product.CartItemSnapshot{},
+            Discounts: []models.DiscountLine{},
+            Bundles: []product.BundleSummary{},
         }
         log.Printf("[DEBUG-SVC] Devolviendo carrito vacío con ID: %s", userID)
         return emptyCartOutput, nil 
     }
 	log.Printf("[DEBUG-SVC] Carrito tiene %d items. Preparando inputs para ms_products", len(cart.Items))
-	productInputs := make([]product.ProductInput, len(cart.Items))
-	for i, item := range cart.Items {
-		productInputs[i] = product.ProductInput{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-		}
+	calculation, err := s.priceCart(ctx, cart)
+	if err != nil {
+		return nil, err
 	}
+
+	s.applyCartDiscount(ctx, cart, calculation)
+	s.applyTaxBreakdown(calculation)
+	log.Printf("[DEBUG-SVC] Cálculo completado. TotalPrice: %d para UserID: %s", calculation.TotalPrice, userID)
+	return calculation, nil
+}
+
+// priceCart calcula el carrito con precios vigentes y el ahorro de los outfits ya descontado, que es el
+// total sobre el que se evalúan los cupones (igual que en el checkout)
+func (s *CartService) priceCart(ctx context.Context, cart *models.Cart) (*product.CartCalculationOutput, error) {
+	productInputs := toProductInputs(cart.Items)
 	log.Printf("[DEBUG-SVC] Llamando a ms_products.CalculateCart para %d items", len(productInputs))
 	calculation, err := s.ProductClient.CalculateCart(ctx, productInputs)
 	if err != nil {
 		return nil, fmt.Errorf("fallo RPC al calcular carrito con ms_products: %w", err)
 	}
-	calculation.UserID = strconv.Itoa(cart.UserID)
-	log.Printf("[DEBUG-SVC] Cálculo completado. TotalPrice: %d para UserID: %s", calculation.TotalPrice, userID)
+	calculation.AttachLineDetails(productInputs)
+	calculation.UserID = cart.UserID
+	calculation.Subtotal = calculation.TotalPrice
+	calculation.Discounts = []models.DiscountLine{}
+
+	savings, err := applyBundlePricing(ctx, s.ProductClient, calculation)
+	if err != nil {
+		return nil, err
+	}
+	if savings > 0 {
+		calculation.Discounts = append(calculation.Discounts, outfitDiscountLine(savings))
+		calculation.TotalPrice -= int(savings)
+	}
 	return calculation, nil
 }
 
-func (s *CartService) RemoveItemFromCart(ctx context.Context, userID string, productID string) (*models.Cart, error) {
-	cart, err := s.Repo.FindByUserID(ctx, userID)
-	if err != nil {
-		return nil, fmt.Errorf("error al buscar carrito para remover item: %w", err)
+// AddOutfitToCart agrega las prendas de un outfit como un grupo. Agregar el mismo outfit
+// otra vez incrementa la cantidad del grupo en lugar de crear uno nuevo.
+func (s *CartService) AddOutfitToCart(ctx context.Context, userID string, pieces []models.CartItem, quantity int) (*models.Cart, error) {
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("el outfit no tiene prendas")
+	}
+	if quantity <= 0 {
+		quantity = 1
 	}
 
-	newItems := []models.CartItem{}
-	for _, item := range cart.Items {
-		if item.ProductID != productID {
+	normalized := make([]models.CartItem, 0, len(pieces))
+	seen := make(map[string]bool, len(pieces))
+	for _, piece := range pieces {
+		if piece.ProductID == "" {
+			return nil, fmt.Errorf("todas las prendas del outfit requieren product_id")
+		}
+		piece.Variant = piece.Variant.Normalize()
+		key := models.LineKey(piece.ProductID, piece.Variant)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, piece)
+	}
+	bundleID := models.BundleIDFor(normalized)
+
+	return s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		newItems := make([]models.CartItem, 0, len(cart.Items)+len(normalized))
+		currentQuantity := 0
+		capturedPrices := make(map[string]int)
+		for _, item := range cart.Items {
+			if item.BundleID == bundleID {
+				currentQuantity = item.Quantity
+				capturedPrices[item.LineKey()] = item.UnitPrice
+				continue
+			}
 			newItems = append(newItems, item)
 		}
+		targetQuantity := currentQuantity + quantity
+
+		pieceItems := make([]models.CartItem, len(normalized))
+		for i, piece := range normalized {
+			pieceItems[i] = models.CartItem{
+				ProductID: piece.ProductID,
+				Variant:   piece.Variant,
+				Quantity:  targetQuantity,
+				BundleID:  bundleID,
+			}
+		}
+		// Las prendas que ya estaban conservan el precio capturado la primera vez
+		if len(capturedPrices) == 0 {
+			prices, err := s.currentUnitPrices(ctx, pieceItems)
+			if err != nil {
+				return err
+			}
+			capturedPrices = prices
+		}
+		for i := range pieceItems {
+			pieceItems[i].UnitPrice = capturedPrices[pieceItems[i].LineKey()]
+		}
+		newItems = append(newItems, pieceItems...)
+
+		// Se valida el total de cada producto del outfit en el carrito (sueltas + outfits, todas las
+		// variantes) en una sola llamada
+		outfitProducts := make(map[string]bool, len(normalized))
+		for _, piece := range normalized {
+			outfitProducts[piece.ProductID] = true
+		}
+		var affected []models.CartItem
+		for _, item := range newItems {
+			if outfitProducts[item.ProductID] {
+				affected = append(affected, item)
+			}
+		}
+
+		validation, rpcErr := s.ProductClient.ValidateStock(ctx, productStockInputs(affected))
+		if rpcErr != nil {
+			return fmt.Errorf("fallo RPC al validar stock con ms_products: %w", rpcErr)
+		}
+		if !validation.Valid {
+			return errors.New(validation.Message)
+		}
+
+		if err := s.checkLimits(ctx, userID, newItems); err != nil {
+			return err
+		}
+
+		cart.Items = newItems
+		return nil
+	})
+}
+
+func (s *CartService) RemoveBundleFromCart(ctx context.Context, userID string, bundleID string) (*models.Cart, error) {
+	cart, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		newItems := []models.CartItem{}
+		for _, item := range cart.Items {
+			if item.BundleID != bundleID {
+				newItems = append(newItems, item)
+			}
+		}
+		cart.Items = newItems
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error al guardar/eliminar carrito después de modificar: %w", err)
 	}
-	cart.Items = newItems
 
-    if len(cart.Items) == 0 {
-        err = s.Repo.DeleteByUserID(ctx, userID)
-    } else {
-        err = s.Repo.Save(ctx, cart)
-    }
+	return cart, nil
+}
 
+func (s *CartService) RemoveItemFromCart(ctx context.Context, userID string, productID string, variant models.Variant) (*models.Cart, error) {
+	lineKey := models.LineKey(productID, variant)
+	cart, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		newItems := []models.CartItem{}
+		for _, item := range cart.Items {
+			if item.LineKey() != lineKey {
+				newItems = append(newItems, item)
+			}
+		}
+		cart.Items = newItems
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error al guardar/eliminar carrito después de modificar: %w", err)
 	}
@@ -177,10 +360,345 @@ func (s *CartService) RemoveItemFromCart(ctx context.Context, userID string, pro
 	return cart, nil
 }
 
+// MergeGuestCart suma el carrito de invitado al del usuario tras el login. Las cantidades
+// combinadas se revalidan contra ms_products; las líneas sin stock conservan la cantidad del usuario.
+func (s *CartService) MergeGuestCart(ctx context.Context, userID string, guestID string) (*models.MergeCartResult, error) {
+	if userID == "" || models.IsGuestCartID(userID) {
+		return nil, fmt.Errorf("se requiere un usuario autenticado para fusionar el carrito")
+	}
+	if guestID == "" {
+		return nil, fmt.Errorf("guest_id es obligatorio")
+	}
+
+	guestCart, err := s.Repo.FindByUserID(ctx, models.GuestCartID(guestID))
+	if err != nil {
+		return nil, fmt.Errorf("error al buscar carrito de invitado: %w", err)
+	}
+
+	result := &models.MergeCartResult{RejectedItems: []models.RejectedCartItem{}}
+
+	if len(guestCart.Items) == 0 {
+		cart, err := s.Repo.FindByUserID(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("error al buscar carrito: %w", err)
+		}
+		result.Cart = cart
+		return result, nil
+	}
+
+	cart, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		result.RejectedItems = []models.RejectedCartItem{}
+
+		merged := make([]models.CartItem, len(cart.Items))
+		copy(merged, cart.Items)
+		userQuantities := make(map[string]int, len(cart.Items))
+		for _, item := range cart.Items {
+			userQuantities[item.LineKey()] = item.Quantity
+		}
+
+		for _, guestItem := range guestCart.Items {
+			found := false
+			for i := range merged {
+				if merged[i].LineKey() == guestItem.LineKey() {
+					merged[i].Quantity += guestItem.Quantity
+					found = true
+					break
+				}
+			}
+			if !found {
+				merged = append(merged, guestItem)
+			}
+		}
+
+		validation, rpcErr := s.ProductClient.ValidateStock(ctx, productStockInputs(merged))
+		if rpcErr != nil {
+			return fmt.Errorf("fallo RPC al validar stock con ms_products: %w", rpcErr)
+		}
+
+		if !validation.Valid {
+			// Si el conjunto no pasa, se valida línea a línea lo que aporta el invitado para conservar lo
+			// que sí tiene stock. Las cantidades que ya tenía el usuario se mantienen y cada aporte se valida
+			// junto a lo ya aceptado del mismo producto (el stock es por producto).
+			log.Printf("[DEBUG-SVC] Fusión de carrito %s con stock insuficiente: %s", userID, validation.Message)
+			accepted := make([]models.CartItem, 0, len(merged))
+			acceptedByProduct := make(map[string]int)
+			for _, item := range cart.Items {
+				acceptedByProduct[item.ProductID] += item.Quantity
+			}
+			for _, item := range merged {
+				guestQuantity := item.Quantity - userQuantities[item.LineKey()]
+				if guestQuantity <= 0 {
+					accepted = append(accepted, item)
+					continue
+				}
+
+				lineValidation, rpcErr := s.ProductClient.ValidateStock(ctx, []product.ProductInput{{
+					ProductID: item.ProductID,
+					Size:      item.Size,
+					Color:     item.Color,
+					Quantity:  acceptedByProduct[item.ProductID] + guestQuantity,
+				}})
+				if rpcErr != nil {
+					return fmt.Errorf("fallo RPC al validar stock con ms_products: %w", rpcErr)
+				}
+				if lineValidation.Valid {
+					accepted = append(accepted, item)
+					acceptedByProduct[item.ProductID] += guestQuantity
+					continue
+				}
+
+				result.RejectedItems = append(result.RejectedItems, models.RejectedCartItem{
+					ProductID: item.ProductID,
+					Variant:   item.Variant,
+					Quantity:  guestQuantity,
+					Reason:    lineValidation.Message,
+				})
+				if qty, ok := userQuantities[item.LineKey()]; ok {
+					item.Quantity = qty
+					accepted = append(accepted, item)
+				}
+			}
+			merged = accepted
+		}
+
+		if err := s.checkLimits(ctx, userID, merged); err != nil {
+			return err
+		}
+
+		cart.Items = merged
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error al fusionar carrito de invitado: %w", err)
+	}
+
+	// Vaciar el carrito de invitado con la versión leída evita borrar cambios posteriores a la fusión
+	guestCart.Items = []models.CartItem{}
+	if err := s.Repo.Save(ctx, guestCart); err != nil {
+		log.Printf("Advertencia: no se pudo eliminar el carrito de invitado %s tras la fusión: %v", guestID, err)
+	}
+
+	result.Cart = cart
+	return result, nil
+}
+
+// applyCartDiscount descuenta el cupón guardado en el carrito; si dejó de ser válido se informa sin descontar
+func (s *CartService) applyCartDiscount(ctx context.Context, cart *models.Cart, calculation *product.CartCalculationOutput) {
+	calculation.CouponCode = cart.CouponCode
+
+	if cart.CouponCode == "" || s.Promotions == nil {
+		return
+	}
+
+	_, discount, err := s.Promotions.Evaluate(ctx, cart.CouponCode, cart.UserID, int64(calculation.TotalPrice))
+	if err != nil {
+		calculation.CouponError = err.Error()
+		return
+	}
+
+	calculation.Discounts = append(calculation.Discounts, *discount)
+	calculation.TotalPrice -= int(discount.Amount)
+}
+
+// applyTaxBreakdown desglosa el IVA por línea (sobre el subtotal) y del total a pagar
+func (s *CartService) applyTaxBreakdown(calculation *product.CartCalculationOutput) {
+	if s.Tax == nil {
+		return
+	}
+	for i := range calculation.Items {
+		line := s.Tax.Breakdown(int64(calculation.Items[i].Subtotal))
+		calculation.Items[i].NetSubtotal = int(line.Net)
+		calculation.Items[i].TaxAmount = int(line.Tax)
+	}
+	total := s.Tax.Breakdown(int64(calculation.TotalPrice))
+	calculation.Tax = &total
+}
+
+func (s *CartService) ApplyCoupon(ctx context.Context, userID string, code string) (*product.CartCalculationOutput, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("el código del cupón es obligatorio")
+	}
+
+	_, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		if len(cart.Items) == 0 {
+			return fmt.Errorf("no se puede aplicar un cupón a un carrito vacío")
+		}
+
+		calculation, err := s.priceCart(ctx, cart)
+		if err != nil {
+			return err
+		}
+
+		coupon, _, err := s.Promotions.Evaluate(ctx, code, userID, int64(calculation.TotalPrice))
+		if err != nil {
+			return err
+		}
+
+		cart.CouponCode = coupon.Code
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCartWithPrices(ctx, userID)
+}
+
+func (s *CartService) RemoveCoupon(ctx context.Context, userID string) (*product.CartCalculationOutput, error) {
+	_, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		cart.CouponCode = ""
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCartWithPrices(ctx, userID)
+}
+
+// AcknowledgePriceChanges acepta los precios vigentes como nuevos precios capturados del carrito
+func (s *CartService) AcknowledgePriceChanges(ctx context.Context, userID string) (*product.CartCalculationOutput, error) {
+	_, err := s.mutateCart(ctx, userID, func(cart *models.Cart) error {
+		if len(cart.Items) == 0 {
+			return nil
+		}
+		prices, err := s.currentUnitPrices(ctx, cart.Items)
+		if err != nil {
+			return err
+		}
+		for i := range cart.Items {
+			if price, ok := prices[cart.Items[i].LineKey()]; ok {
+				cart.Items[i].UnitPrice = price
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCartWithPrices(ctx, userID)
+}
+
 func (s *CartService) ClearCartByUserID(ctx context.Context, userID string) error {
 	err := s.Repo.DeleteByUserID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("error al eliminar completamente el carrito: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// toProductInputs traduce líneas del carrito (con su variante) al formato de ms_products
+func toProductInputs(items []models.CartItem) []product.ProductInput {
+	inputs := make([]product.ProductInput, len(items))
+	for i, item := range items {
+		inputs[i] = product.ProductInput{
+			ProductID: item.ProductID,
+			Size:      item.Size,
+			Color:     item.Color,
+			Quantity:  item.Quantity,
+			BundleID:  item.BundleID,
+			CapturedPrice: item.UnitPrice,
+		}
+	}
+	return inputs
+}
+
+// productStockInputs suma las cantidades por producto para validar stock: ms_products lleva el stock por
+// producto, así que todas las tallas/colores de un producto compiten por las mismas unidades
+func productStockInputs(items []models.CartItem) []product.ProductInput {
+	positions := make(map[string]int, len(items))
+	var inputs []product.ProductInput
+	for _, item := range items {
+		if pos, ok := positions[item.ProductID]; ok {
+			inputs[pos].Quantity += item.Quantity
+			continue
+		}
+		positions[item.ProductID] = len(inputs)
+		inputs = append(inputs, product.ProductInput{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+	return inputs
+}
+
+// currentUnitPrices consulta los precios vigentes de las líneas, indexados por LineKey
+func (s *CartService) currentUnitPrices(ctx context.Context, items []models.CartItem) (map[string]int, error) {
+	inputs := toProductInputs(items)
+	calculation, err := s.ProductClient.CalculateCart(ctx, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("fallo RPC al consultar precios con ms_products: %w", err)
+	}
+	calculation.AttachLineDetails(inputs)
+
+	prices := make(map[string]int, len(calculation.Items))
+	for _, snapshot := range calculation.Items {
+		prices[snapshot.LineKey()] = snapshot.UnitPrice
+	}
+	return prices, nil
+}
+
+// applyBundlePricing agrupa los snapshots por outfit y los precia con calculate_outfit_price.
+// Devuelve el ahorro cuando el precio del outfit es menor a la suma de sus prendas.
+func applyBundlePricing(ctx context.Context, client product.ClientInterface, calculation *product.CartCalculationOutput) (int64, error) {
+	calculation.Bundles = []product.BundleSummary{}
+
+	order := []string{}
+	pieces := make(map[string][]product.CartItemSnapshot)
+	for _, item := range calculation.Items {
+		if item.BundleID == "" {
+			continue
+		}
+		if _, ok := pieces[item.BundleID]; !ok {
+			order = append(order, item.BundleID)
+		}
+		pieces[item.BundleID] = append(pieces[item.BundleID], item)
+	}
+
+	var savings int64
+	for _, bundleID := range order {
+		group := pieces[bundleID]
+		quantity := group[0].Quantity
+
+		piecesPrice := 0
+		productIDs := make([]string, 0, len(group))
+		seen := make(map[string]bool, len(group))
+		for _, piece := range group {
+			piecesPrice += piece.UnitPrice
+			if !seen[piece.ProductID] {
+				seen[piece.ProductID] = true
+				productIDs = append(productIDs, piece.ProductID)
+			}
+		}
+
+		outfit, err := client.CalculateOutfitPrice(ctx, productIDs)
+		if err != nil {
+			return 0, fmt.Errorf("fallo RPC al calcular precio del outfit: %w", err)
+		}
+
+		unitPrice := outfit.TotalPrice
+		if unitPrice <= 0 || unitPrice > piecesPrice {
+			unitPrice = piecesPrice
+		}
+		savings += int64(piecesPrice-unitPrice) * int64(quantity)
+
+		calculation.Bundles = append(calculation.Bundles, product.BundleSummary{
+			BundleID:   bundleID,
+			ProductIDs: productIDs,
+			Quantity:   quantity,
+			UnitPrice:  unitPrice,
+			Subtotal:   unitPrice * quantity,
+		})
+	}
+
+	return savings, nil
+}
+
+func outfitDiscountLine(amount int64) models.DiscountLine {
+	return models.DiscountLine{
+		Code:        "OUTFIT",
+		Description: "Precio de outfit",
+		Amount:      amount,
+	}
+}
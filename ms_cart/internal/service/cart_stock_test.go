@@ -0,0 +1,30 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+)
+
+func TestProductStockInputsSumsVariantsPerProduct(t *testing.T) {
+	items := []models.CartItem{
+		{ProductID: "p1", Variant: models.Variant{Size: "S"}, Quantity: 2},
+		{ProductID: "p2", Variant: models.Variant{Size: "M"}, Quantity: 1},
+		{ProductID: "p1", Variant: models.Variant{Size: "L", Color: "rojo"}, Quantity: 3},
+	}
+
+	got := productStockInputs(items)
+	want := []product.ProductInput{
+		{ProductID: "p1", Quantity: 5},
+		{ProductID: "p2", Quantity: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("productStockInputs = %+v, want %+v", got, want)
+	}
+
+	if got := productStockInputs(nil); len(got) != 0 {
+		t.Errorf("sin items = %+v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultIdempotencyWindow es el tiempo durante el que se recuerda una clave de checkout
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+const (
+	maxIdempotencyKeyLength = 100
+	// checkoutPendingMarker ocupa la clave en Redis mientras el primer checkout está en curso
+	checkoutPendingMarker = "pending"
+	// checkoutPendingTTL es lo que dura el marcador si el proceso muere a mitad del checkout: pasado ese
+	// plazo el mensaje reencolado (o el cliente) puede reintentar con la misma clave. Cubre con holgura
+	// lo que tarda un checkout normal (ms_products + preferencia de MP).
+	checkoutPendingTTL = 2 * time.Minute
+)
+
+func checkoutIdempotencyKey(userID, key string) string {
+	return "checkout_idem:" + userID + ":" + key
+}
+
+// procesarCompraIdempotente reclama la clave en Redis con SETNX antes de crear la orden. Si el checkout
+// falla la clave se libera para que el cliente pueda reintentar; si termina bien guarda el ID de la orden.
+func (s *OrderService) procesarCompraIdempotente(ctx context.Context, userID string, shippingAddress models.Address, key string) (*models.CheckoutResponse, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("la clave de idempotencia debe tener entre 1 y %d caracteres", maxIdempotencyKeyLength)
+	}
+
+	response, err := s.replayCheckout(ctx, userID, key)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	redisKey := checkoutIdempotencyKey(userID, key)
+	claimed, err := s.RedisClient.SetNX(ctx, redisKey, checkoutPendingMarker, checkoutPendingTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("fallo al reservar la clave de idempotencia: %w", err)
+	}
+	if !claimed {
+		// Otra petición ganó la carrera entre la búsqueda y el SETNX
+		response, err := s.replayCheckout(ctx, userID, key)
+		if response != nil || err != nil {
+			return response, err
+		}
+		return nil, &CheckoutInProgressError{IdempotencyKey: key}
+	}
+
+	response, err = s.procesarCompra(ctx, userID, shippingAddress, key)
+	if err != nil {
+		if delErr := s.RedisClient.Del(context.Background(), redisKey).Err(); delErr != nil {
+			log.Printf("Advertencia: no se liberó la clave de idempotencia %s: %v", redisKey, delErr)
+		}
+		return nil, err
+	}
+
+	if setErr := s.RedisClient.Set(context.Background(), redisKey, strconv.FormatUint(uint64(response.OrderID), 10), s.IdempotencyWindow).Err(); setErr != nil {
+		// La orden guarda la clave, así que el respaldo en Postgres sigue detectando el duplicado
+		log.Printf("Advertencia: no se guardó la clave de idempotencia %s: %v", redisKey, setErr)
+	}
+	return response, nil
+}
+
+// replayCheckout devuelve la respuesta del checkout original para la clave, o nil si no hay uno registrado
+func (s *OrderService) replayCheckout(ctx context.Context, userID, key string) (*models.CheckoutResponse, error) {
+	value, err := s.RedisClient.Get(ctx, checkoutIdempotencyKey(userID, key)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("fallo al leer la clave de idempotencia: %w", err)
+	}
+
+	switch {
+	case value == checkoutPendingMarker:
+		return nil, &CheckoutInProgressError{IdempotencyKey: key}
+
+	case value != "":
+		orderID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("valor de idempotencia corrupto para la clave %s", key)
+		}
+		order, err := s.OrderRepo.FindByID(ctx, uint(orderID))
+		if err != nil {
+			return nil, fmt.Errorf("orden original no encontrada para la clave %s: %w", key, err)
+		}
+		return checkoutResponseFor(order), nil
+	}
+
+	// Sin registro en Redis (expiró o se perdió): se busca en Postgres dentro de la misma ventana
+	userIDUint64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
+	}
+	order, err := s.OrderRepo.FindByIdempotencyKey(ctx, uint(userIDUint64), key, time.Now().Add(-s.IdempotencyWindow))
+	if err != nil || order == nil {
+		return nil, err
+	}
+	return checkoutResponseFor(order), nil
+}
+
+func checkoutResponseFor(order *models.Order) *models.CheckoutResponse {
+	return &models.CheckoutResponse{
+		OrderID:    order.ID,
+		Status:     order.Status,
+		PaymentURL: order.PaymentURL,
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+// CodedError permite que el listener RPC devuelva un código estable y detalles además del mensaje
+type CodedError interface {
+	error
+	Code() string
+	Details() interface{}
+}
+
+// PricesChangedError se devuelve en el checkout cuando algún precio cambió desde que se agregó al carrito
+type PricesChangedError struct {
+	Changes []models.PriceChange
+}
+
+func (e *PricesChangedError) Error() string {
+	return fmt.Sprintf("los precios de %d producto(s) cambiaron; confirma los nuevos totales antes de pagar", len(e.Changes))
+}
+
+func (e *PricesChangedError) Code() string {
+	return "PRICES_CHANGED"
+}
+
+func (e *PricesChangedError) Details() interface{} {
+	return e.Changes
+}
+
+// CheckoutInProgressError se devuelve cuando llega la misma clave de idempotencia mientras el primer checkout sigue en curso
+type CheckoutInProgressError struct {
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+func (e *CheckoutInProgressError) Error() string {
+	return "ya hay un checkout en curso con esta clave de idempotencia; reintenta en unos segundos"
+}
+
+func (e *CheckoutInProgressError) Code() string {
+	return "CHECKOUT_IN_PROGRESS"
+}
+
+func (e *CheckoutInProgressError) Details() interface{} {
+	return e
+}
+
+// LimitExceededError se devuelve cuando el carrito supera un límite de compra configurado
+type LimitExceededError struct {
+	Limit     string `json:"limit"`
+	ProductID string `json:"product_id,omitempty"`
+	Max       int64  `json:"max"`
+	Actual    int64  `json:"actual"`
+	message   string
+}
+
+func (e *LimitExceededError) Error() string {
+	return e.message
+}
+
+func (e *LimitExceededError) Code() string {
+	return "LIMIT_EXCEEDED"
+}
+
+func (e *LimitExceededError) Details() interface{} {
+	return e
+}
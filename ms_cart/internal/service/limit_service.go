@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+	"github.com/C0kke/FitFashion/ms_cart/pkg/config"
+)
+
+const (
+	LimitLineQuantity   = "max_line_quantity"
+	LimitDistinctLines  = "max_distinct_lines"
+	LimitCartValue      = "max_cart_value"
+	LimitPerUserProduct = "max_per_user_product"
+)
+
+// PurchaseLimits son los límites globales del carrito; 0 desactiva el límite
+type PurchaseLimits struct {
+	MaxLineQuantity  int
+	MaxDistinctLines int
+	MaxCartValue     int64
+}
+
+// LoadPurchaseLimitsFromEnv lee CART_MAX_LINE_QUANTITY, CART_MAX_DISTINCT_LINES y CART_MAX_VALUE
+func LoadPurchaseLimitsFromEnv() PurchaseLimits {
+	return PurchaseLimits{
+		MaxLineQuantity:  int(config.Int("CART_MAX_LINE_QUANTITY", 20)),
+		MaxDistinctLines: int(config.Int("CART_MAX_DISTINCT_LINES", 30)),
+		MaxCartValue:     config.Int("CART_MAX_VALUE", 0),
+	}
+}
+
+type LimitService struct {
+	Limits        PurchaseLimits
+	ProductLimits repository.ProductLimitRepository
+	OrderRepo     repository.OrderRepository
+}
+
+func NewLimitService(limits PurchaseLimits, productLimits repository.ProductLimitRepository, orderRepo repository.OrderRepository) *LimitService {
+	return &LimitService{
+		Limits:        limits,
+		ProductLimits: productLimits,
+		OrderRepo:     orderRepo,
+	}
+}
+
+// CheckCart valida las líneas del carrito contra los límites globales y los límites por usuario
+// de productos de edición limitada (que consideran además las órdenes vigentes del usuario).
+func (s *LimitService) CheckCart(ctx context.Context, userID string, items []models.CartItem) error {
+	if s.Limits.MaxDistinctLines > 0 && len(items) > s.Limits.MaxDistinctLines {
+		return &LimitExceededError{
+			Limit:   LimitDistinctLines,
+			Max:     int64(s.Limits.MaxDistinctLines),
+			Actual:  int64(len(items)),
+			message: fmt.Sprintf("el carrito no puede tener más de %d productos distintos", s.Limits.MaxDistinctLines),
+		}
+	}
+
+	var value int64
+	perProduct := make(map[string]int)
+	productIDs := []string{}
+	for _, item := range items {
+		if s.Limits.MaxLineQuantity > 0 && item.Quantity > s.Limits.MaxLineQuantity {
+			return &LimitExceededError{
+				Limit:     LimitLineQuantity,
+				ProductID: item.ProductID,
+				Max:       int64(s.Limits.MaxLineQuantity),
+				Actual:    int64(item.Quantity),
+				message:   fmt.Sprintf("no se pueden agregar más de %d unidades del producto %s", s.Limits.MaxLineQuantity, item.ProductID),
+			}
+		}
+		value += int64(item.UnitPrice) * int64(item.Quantity)
+		if _, ok := perProduct[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		perProduct[item.ProductID] += item.Quantity
+	}
+
+	if s.Limits.MaxCartValue > 0 && value > s.Limits.MaxCartValue {
+		return &LimitExceededError{
+			Limit:   LimitCartValue,
+			Max:     s.Limits.MaxCartValue,
+			Actual:  value,
+			message: fmt.Sprintf("el valor del carrito no puede superar $%d", s.Limits.MaxCartValue),
+		}
+	}
+
+	if s.ProductLimits == nil || len(productIDs) == 0 {
+		return nil
+	}
+	limits, err := s.ProductLimits.FindByProductIDs(ctx, productIDs)
+	if err != nil {
+		return fmt.Errorf("error al consultar límites de productos: %w", err)
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	purchased := map[string]int{}
+	if userIDUint, err := strconv.ParseUint(userID, 10, 64); err == nil {
+		limitedIDs := make([]string, len(limits))
+		for i, limit := range limits {
+			limitedIDs[i] = limit.ProductID
+		}
+		purchased, err = s.OrderRepo.SumPurchasedQuantities(ctx, uint(userIDUint), limitedIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, limit := range limits {
+		total := perProduct[limit.ProductID] + purchased[limit.ProductID]
+		if total > limit.MaxPerUser {
+			return &LimitExceededError{
+				Limit:     LimitPerUserProduct,
+				ProductID: limit.ProductID,
+				Max:       int64(limit.MaxPerUser),
+				Actual:    int64(total),
+				message:   fmt.Sprintf("el producto %s es de edición limitada: máximo %d unidades por usuario", limit.ProductID, limit.MaxPerUser),
+			}
+		}
+	}
+	return nil
+}
+
+// SetProductLimit define (o elimina con maxPerUser = 0) el límite por usuario de un producto
+func (s *LimitService) SetProductLimit(ctx context.Context, productID string, maxPerUser int) (*models.ProductPurchaseLimit, error) {
+	if productID == "" {
+		return nil, fmt.Errorf("product_id es obligatorio")
+	}
+	if maxPerUser < 0 {
+		return nil, fmt.Errorf("max_per_user no puede ser negativo")
+	}
+	if maxPerUser == 0 {
+		return nil, s.ProductLimits.Delete(ctx, productID)
+	}
+
+	limit := &models.ProductPurchaseLimit{ProductID: productID, MaxPerUser: maxPerUser}
+	if err := s.ProductLimits.Upsert(ctx, limit); err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
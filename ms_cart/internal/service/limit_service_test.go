@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+type staticProductLimits struct {
+	repository.ProductLimitRepository
+	limits []models.ProductPurchaseLimit
+}
+
+func (r *staticProductLimits) FindByProductIDs(ctx context.Context, productIDs []string) ([]models.ProductPurchaseLimit, error) {
+	return r.limits, nil
+}
+
+// purchasedOrderRepository suma las unidades de orders con el mismo filtro de estados que PostgreSQL
+type purchasedOrderRepository struct {
+	repository.OrderRepository
+	orders []models.Order
+}
+
+func (r *purchasedOrderRepository) SumPurchasedQuantities(ctx context.Context, userID uint, productIDs []string) (map[string]int, error) {
+	counted := make(map[models.OrderStatus]bool, len(models.PurchaseLimitOrderStatuses))
+	for _, status := range models.PurchaseLimitOrderStatuses {
+		counted[status] = true
+	}
+	quantities := make(map[string]int)
+	for _, order := range r.orders {
+		if order.UserID != userID || !counted[order.Status] {
+			continue
+		}
+		for _, item := range order.OrderItems {
+			quantities[item.ProductID] += item.Quantity
+		}
+	}
+	return quantities, nil
+}
+
+func TestCheckCartPerUserLimitCountsShippedOrders(t *testing.T) {
+	orderWith := func(status models.OrderStatus, quantity int) models.Order {
+		return models.Order{UserID: 7, Status: status, OrderItems: []models.OrderItem{{ProductID: "ed-limitada", Quantity: quantity}}}
+	}
+	cases := []struct {
+		status models.OrderStatus
+		counts bool
+	}{
+		{models.OrderStatusPendiente, true},
+		{models.OrderStatusPagado, true},
+		{models.OrderStatusEnviado, true},
+		{models.OrderStatusEntregado, true},
+		{models.OrderStatusReembolsoParcial, true},
+		{models.OrderStatusReembolsado, false},
+		{models.OrderStatusCancelado, false},
+		{models.OrderStatusExpirado, false},
+	}
+	for _, c := range cases {
+		t.Run(string(c.status), func(t *testing.T) {
+			s := &LimitService{
+				ProductLimits: &staticProductLimits{limits: []models.ProductPurchaseLimit{{ProductID: "ed-limitada", MaxPerUser: 2}}},
+				OrderRepo:     &purchasedOrderRepository{orders: []models.Order{orderWith(c.status, 2)}},
+			}
+
+			err := s.CheckCart(context.Background(), "7", []models.CartItem{{ProductID: "ed-limitada", Quantity: 1}})
+			var limitErr *LimitExceededError
+			if exceeded := errors.As(err, &limitErr); exceeded != c.counts {
+				t.Fatalf("límite superado = %v, want %v (err %v)", exceeded, c.counts, err)
+			}
+			if c.counts && (limitErr.Limit != LimitPerUserProduct || limitErr.Actual != 3) {
+				t.Errorf("got %+v", limitErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+var ErrOrderNotFound = errors.New("orden no encontrada")
+
+// CancelOrder cancela una orden del usuario, devuelve el stock y publica order.cancelled. La cancelación
+// solo se aplica si la orden sigue en el estado leído (un webhook o un despacho concurrente la rechaza) y,
+// si la orden estaba pagada, el pago se reembolsa recién después con una clave de idempotencia fija por
+// orden. Si el reembolso falla la orden queda CANCELADO y volver a cancelarla reintenta el reembolso.
+// Las órdenes de cambio no tienen pago propio: se cancelan sin reembolso.
+func (s *OrderService) CancelOrder(ctx context.Context, userID string, orderID uint, reason string) (*models.Order, error) {
+	userIDUint64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
+	}
+
+	order, err := s.OrderRepo.FindByID(ctx, orderID)
+	// Una orden ajena se reporta igual que una inexistente
+	if err != nil || order.UserID != uint(userIDUint64) {
+		return nil, ErrOrderNotFound
+	}
+
+	refundable := order.PaymentID != "" && order.ExchangeForOrderID == nil
+	retryRefund := order.Status == models.OrderStatusCancelado && refundable
+	if !retryRefund && !order.Status.CancellableByUser() {
+		return nil, &models.InvalidStatusTransitionError{OrderID: order.ID, From: order.Status, To: models.OrderStatusCancelado}
+	}
+	if refundable && order.Total <= 0 {
+		return nil, fmt.Errorf("la orden #%d no tiene monto que reembolsar", order.ID)
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "cancelada por el cliente"
+	}
+
+	if !retryRefund {
+		err = s.OrderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusCancelado, models.StatusChange{
+			Source:         models.StatusSourceUser,
+			PaymentID:      order.PaymentID,
+			Reason:         reason,
+			EventType:      models.OrderEventCancelled,
+			ExpectedStatus: order.Status,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.Reservations.Restock(ctx, order, reason); err != nil {
+			log.Printf("[ERROR-CRITICO] Orden #%d cancelada sin devolver su stock: %v", order.ID, err)
+		}
+		order.Status = models.OrderStatusCancelado
+	}
+
+	if refundable {
+		key := fmt.Sprintf("refund-order-%d-cancel", order.ID)
+		if _, err := s.issueRefund(ctx, order.ID, order.PaymentID, order.Total, key, reason, models.StatusSourceUser); err != nil {
+			log.Printf("[ERROR-CRITICO] Orden #%d cancelada sin reembolsar el pago #%s: %v", order.ID, order.PaymentID, err)
+			return nil, fmt.Errorf("la orden #%d quedó cancelada pero el reembolso falló; vuelve a cancelarla para reintentarlo: %w", order.ID, err)
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/payments"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// racingOrderRepository devuelve en FindByID la orden tal como la vio el llamador (read) y aplica
+// UpdateStatus sobre el estado guardado (stored), que pudo cambiar entre medio
+type racingOrderRepository struct {
+	repository.OrderRepository
+	read    models.Order
+	stored  models.OrderStatus
+	journal *[]string
+}
+
+func (r *racingOrderRepository) FindByID(ctx context.Context, orderID uint) (*models.Order, error) {
+	order := r.read
+	return &order, nil
+}
+
+func (r *racingOrderRepository) UpdateStatus(ctx context.Context, orderID uint, status models.OrderStatus, change models.StatusChange) error {
+	if err := change.Check(orderID, r.stored, status); err != nil {
+		return err
+	}
+	r.stored = status
+	*r.journal = append(*r.journal, "status:"+string(status))
+	return nil
+}
+
+type memoryRefundRepository struct {
+	repository.RefundRepository
+	refunds []models.Refund
+}
+
+func (r *memoryRefundRepository) Create(ctx context.Context, refund *models.Refund) error {
+	r.refunds = append(r.refunds, *refund)
+	return nil
+}
+
+func (r *memoryRefundRepository) FindByIdempotencyKey(ctx context.Context, key string) (*models.Refund, error) {
+	for i := range r.refunds {
+		if r.refunds[i].IdempotencyKey == key {
+			return &r.refunds[i], nil
+		}
+	}
+	return nil, nil
+}
+
+type refundPaymentClient struct {
+	payments.PaymentClient
+	err     error
+	journal *[]string
+}
+
+func (c *refundPaymentClient) RefundPayment(ctx context.Context, paymentID string, amount int64, idempotencyKey string) (*payments.RefundDetails, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	*c.journal = append(*c.journal, fmt.Sprintf("refund:%d", amount))
+	return &payments.RefundDetails{ID: "r-" + idempotencyKey, Status: "approved", Amount: amount}, nil
+}
+
+type noopReservationRepository struct {
+	repository.ReservationRepository
+}
+
+func (noopReservationRepository) Transition(ctx context.Context, orderID uint, from string, to string, reason string) (bool, error) {
+	return false, nil
+}
+
+func newCancellationService(read models.Order, stored models.OrderStatus) (*OrderService, *racingOrderRepository, *refundPaymentClient, *memoryRefundRepository, *[]string) {
+	journal := &[]string{}
+	repo := &racingOrderRepository{read: read, stored: stored, journal: journal}
+	client := &refundPaymentClient{journal: journal}
+	refunds := &memoryRefundRepository{}
+	s := &OrderService{
+		OrderRepo:     repo,
+		PaymentClient: client,
+		Refunds:       refunds,
+		Reservations:  &ReservationService{Repo: noopReservationRepository{}},
+	}
+	return s, repo, client, refunds, journal
+}
+
+func paidOrder(status models.OrderStatus) models.Order {
+	order := models.Order{UserID: 7, Status: status, Total: 15000}
+	order.ID = 42
+	if status != models.OrderStatusPendiente {
+		order.PaymentID = "pay-1"
+	}
+	return order
+}
+
+func TestCancelOrderRefundsAfterCancelling(t *testing.T) {
+	s, repo, _, refunds, journal := newCancellationService(paidOrder(models.OrderStatusPagado), models.OrderStatusPagado)
+
+	order, err := s.CancelOrder(context.Background(), "7", 42, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderStatusCancelado || repo.stored != models.OrderStatusCancelado {
+		t.Errorf("estado: got %s / guardado %s", order.Status, repo.stored)
+	}
+	want := []string{"status:CANCELADO", "refund:15000"}
+	if fmt.Sprint(*journal) != fmt.Sprint(want) {
+		t.Errorf("orden de operaciones: got %v, want %v", *journal, want)
+	}
+	if len(refunds.refunds) != 1 {
+		t.Errorf("reembolsos registrados: %d", len(refunds.refunds))
+	}
+}
+
+func TestCancelOrderStatusChangedBeforeUpdate(t *testing.T) {
+	cases := []struct {
+		name   string
+		read   models.Order
+		stored models.OrderStatus
+	}{
+		// Un webhook pagó la orden después de leerla: no se puede cancelar sin reembolsar
+		{"pagada por webhook", paidOrder(models.OrderStatusPendiente), models.OrderStatusPagado},
+		// Un admin la despachó después de leerla: no se reembolsa una orden enviada
+		{"despachada", paidOrder(models.OrderStatusPagado), models.OrderStatusEnviado},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, repo, _, refunds, journal := newCancellationService(c.read, c.stored)
+
+			_, err := s.CancelOrder(context.Background(), "7", 42, "")
+			var changed *models.StatusChangedError
+			if !errors.As(err, &changed) {
+				t.Fatalf("se esperaba StatusChangedError, got %v", err)
+			}
+			if repo.stored != c.stored {
+				t.Errorf("la orden no debe cambiar: got %s", repo.stored)
+			}
+			if len(*journal) != 0 || len(refunds.refunds) != 0 {
+				t.Errorf("no debe cancelar ni reembolsar: %v", *journal)
+			}
+		})
+	}
+}
+
+func TestCancelOrderRetriesFailedRefund(t *testing.T) {
+	s, repo, client, refunds, journal := newCancellationService(paidOrder(models.OrderStatusPagado), models.OrderStatusPagado)
+	client.err = errors.New("MP no disponible")
+
+	if _, err := s.CancelOrder(context.Background(), "7", 42, ""); err == nil {
+		t.Fatal("se esperaba error del reembolso")
+	}
+	if repo.stored != models.OrderStatusCancelado || len(refunds.refunds) != 0 {
+		t.Fatalf("la orden queda cancelada sin reembolso: %s, %d", repo.stored, len(refunds.refunds))
+	}
+
+	// El reintento sobre la orden ya cancelada solo emite el reembolso pendiente
+	client.err = nil
+	repo.read.Status = models.OrderStatusCancelado
+	if _, err := s.CancelOrder(context.Background(), "7", 42, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelOrder(context.Background(), "7", 42, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"status:CANCELADO", "refund:15000"}
+	if fmt.Sprint(*journal) != fmt.Sprint(want) || len(refunds.refunds) != 1 {
+		t.Errorf("got %v y %d reembolsos, want %v y 1", *journal, len(refunds.refunds), want)
+	}
+}
+
+func TestCancelOrderRejectsOtherUsersAndFinalStatuses(t *testing.T) {
+	s, _, _, _, _ := newCancellationService(paidOrder(models.OrderStatusPagado), models.OrderStatusPagado)
+	if _, err := s.CancelOrder(context.Background(), "8", 42, ""); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("orden ajena: got %v", err)
+	}
+
+	s, _, _, _, _ = newCancellationService(paidOrder(models.OrderStatusEnviado), models.OrderStatusEnviado)
+	var invalid *models.InvalidStatusTransitionError
+	if _, err := s.CancelOrder(context.Background(), "7", 42, ""); !errors.As(err, &invalid) {
+		t.Errorf("orden enviada: got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// NewExchangeOrder arma (sin guardarla) la orden de cambio de una devolución: las mismas prendas con la
+// talla/color nuevos, ya pagadas con la orden original (total 0). No lleva el PaymentID de la original
+// para que cancelarla nunca reembolse ese pago.
+func (s *OrderService) NewExchangeOrder(original *models.Order, ret *models.ReturnRequest, returned []models.OrderItem) (*models.Order, models.StatusChange) {
+	items := make([]models.OrderItem, len(returned))
+	var value int64
+	for i, item := range returned {
+		variant := models.Variant{Size: item.Size, Color: item.Color}
+		if size := ret.Items[i].ExchangeSize; size != "" {
+			variant.Size = size
+		}
+		if color := ret.Items[i].ExchangeColor; color != "" {
+			variant.Color = color
+		}
+
+		items[i] = models.OrderItem{
+			ProductID:    item.ProductID,
+			NameSnapshot: item.NameSnapshot,
+			Size:         variant.Size,
+			Color:        variant.Color,
+			UnitPrice:    item.UnitPrice,
+			Quantity:     item.Quantity,
+		}
+		value += item.UnitPrice * int64(item.Quantity)
+	}
+
+	originalID := original.ID
+	exchange := &models.Order{
+		UserID:             original.UserID,
+		Subtotal:           value,
+		DiscountTotal:      value,
+		Total:              0,
+		Status:             models.OrderStatusPagado,
+		ShippingAddress:    original.ShippingAddress,
+		ShippingDetails:    original.ShippingDetails,
+		ExchangeForOrderID: &originalID,
+		OrderItems:         items,
+	}
+	s.applyTaxBreakdown(exchange)
+
+	change := models.StatusChange{
+		Source:    models.StatusSourceAdmin,
+		Reason:    fmt.Sprintf("cambio por devolución #%d de la orden #%d (pago #%s)", ret.ID, original.ID, original.PaymentID),
+		EventType: models.OrderEventCreated,
+	}
+	return exchange, change
+}
+
+// FulfillExchangeOrder descuenta y confirma el stock de una orden de cambio ya guardada. En un reintento
+// retoma la reserva existente en vez de descontar de nuevo.
+func (s *OrderService) FulfillExchangeOrder(ctx context.Context, exchange *models.Order) error {
+	_, err := s.Reservations.Repo.FindByOrderID(ctx, exchange.ID)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		if err := s.Reservations.Reserve(ctx, exchange); err != nil {
+			if statusErr := s.OrderRepo.UpdateStatus(ctx, exchange.ID, models.OrderStatusStockFallido, models.StatusChange{
+				Source: models.StatusSourceSystem,
+				Reason: err.Error(),
+			}); statusErr != nil {
+				log.Printf("Advertencia: no se pudo marcar la orden de cambio #%d como STOCK_FALLIDO: %v", exchange.ID, statusErr)
+			}
+			return fmt.Errorf("sin stock para la orden de cambio #%d: %w", exchange.ID, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := s.Reservations.Commit(ctx, exchange); err != nil {
+		log.Printf("Advertencia: reserva de la orden de cambio #%d sin confirmar: %v", exchange.ID, err)
+	}
+	return nil
+}
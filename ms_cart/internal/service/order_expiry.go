@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+const expiredOrdersBatchSize = 100
+
+// StartPendingExpirySweeper marca periódicamente como EXPIRADO las órdenes sin pagar cuyo plazo venció
+func (s *OrderService) StartPendingExpirySweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.ExpireUnpaidOrders(ctx)
+			}
+		}
+	}()
+	log.Printf("Expiración de órdenes impagas iniciada (plazo %s, cada %s)", s.PendingOrderTTL, interval)
+}
+
+// ExpireUnpaidOrders expira las órdenes vencidas y devuelve su stock. Si un pago llega justo antes,
+// la transición falla y la orden queda como está.
+func (s *OrderService) ExpireUnpaidOrders(ctx context.Context) {
+	orders, err := s.OrderRepo.FindExpiredUnpaid(ctx, time.Now(), s.PendingOrderTTL, expiredOrdersBatchSize)
+	if err != nil {
+		log.Printf("Error buscando órdenes vencidas: %v", err)
+		return
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		err := s.OrderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusExpirado, models.StatusChange{
+			Source:    models.StatusSourceSystem,
+			Reason:    "pago no recibido dentro del plazo",
+			EventType: models.OrderEventExpired,
+		})
+		var invalid *models.InvalidStatusTransitionError
+		if errors.As(err, &invalid) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error expirando la orden #%d: %v", order.ID, err)
+			continue
+		}
+
+		if err := s.Reservations.Release(ctx, order, "orden expirada"); err != nil {
+			log.Printf("Advertencia: orden #%d expirada sin liberar su stock: %v", order.ID, err)
+		}
+		log.Printf("Orden #%d expirada", order.ID)
+	}
+}
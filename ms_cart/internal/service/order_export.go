@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/export"
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+// DefaultExportMaxOrders es el tope de órdenes que se exportan en una sola respuesta RPC
+const DefaultExportMaxOrders = 10000
+
+// OrderExportRequest son los filtros de export_orders. El rango es semiabierto: created_to no se incluye.
+type OrderExportRequest struct {
+	CreatedFrom *time.Time           `json:"created_from"`
+	CreatedTo   *time.Time           `json:"created_to"`
+	Statuses    []models.OrderStatus `json:"status"`
+	Format      string               `json:"format"`
+}
+
+type OrderExport struct {
+	Format      string `json:"format"`
+	ContentType string `json:"content_type"`
+	Orders      int    `json:"orders"`
+	Content     string `json:"content"`
+}
+
+// ExportOrders arma el export contable de las órdenes (CSV o JSON Lines) con el mismo layout que cmd/export
+func (s *OrderService) ExportOrders(ctx context.Context, request OrderExportRequest) (*OrderExport, error) {
+	format, err := export.ParseFormat(request.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	query := models.OrderQuery{
+		Statuses:    request.Statuses,
+		CreatedFrom: request.CreatedFrom,
+		CreatedTo:   request.CreatedTo,
+	}
+
+	var buf bytes.Buffer
+	count, err := export.WriteOrders(ctx, s.OrderRepo, query, format, &buf, s.ExportMaxOrders)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderExport{
+		Format:      format,
+		ContentType: export.ContentType(format),
+		Orders:      count,
+		Content:     buf.String(),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+// RefundOrder reembolsa amount de una orden pagada (0 = todo lo que queda por reembolsar), registra el
+// reembolso y deja la orden en REEMBOLSADO o REEMBOLSO_PARCIAL según lo acumulado.
+func (s *OrderService) RefundOrder(ctx context.Context, orderID uint, amount int64, reason string, source models.OrderStatusSource) (*models.Refund, error) {
+	return s.refundOrder(ctx, orderID, amount, reason, source, "")
+}
+
+// refundOrder es RefundOrder con una clave de idempotencia fija del llamador (vacía = una clave por
+// reembolso de la orden). Si ya existe un reembolso con esa clave no se emite otro: se devuelve ese y
+// solo se corrige el estado de la orden si quedó atrás.
+func (s *OrderService) refundOrder(ctx context.Context, orderID uint, amount int64, reason string, source models.OrderStatusSource, key string) (*models.Refund, error) {
+	order, err := s.OrderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.ExchangeForOrderID != nil {
+		return nil, fmt.Errorf("la orden #%d es una orden de cambio y no tiene pago que reembolsar", order.ID)
+	}
+	if amount < 0 {
+		return nil, fmt.Errorf("el monto a reembolsar no puede ser negativo")
+	}
+
+	previous, err := s.Refunds.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	var refunded int64
+	for _, r := range previous {
+		refunded += r.Amount
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "reembolso"
+	}
+
+	if key != "" {
+		for i := range previous {
+			if previous[i].IdempotencyKey != key {
+				continue
+			}
+			if next := refundedStatus(order, refunded); order.Status != next {
+				if err := s.markRefunded(ctx, order, next, &previous[i], reason, source); err != nil {
+					return nil, err
+				}
+			}
+			return &previous[i], nil
+		}
+	}
+
+	if order.PaymentID == "" || !order.Status.CanTransitionTo(models.OrderStatusReembolsoParcial) {
+		return nil, &models.InvalidStatusTransitionError{OrderID: order.ID, From: order.Status, To: models.OrderStatusReembolsado}
+	}
+
+	remaining := order.Total - refunded
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return nil, fmt.Errorf("monto a reembolsar inválido: quedan %d por reembolsar en la orden #%d", remaining, order.ID)
+	}
+
+	// La clave depende de cuántos reembolsos hay: si el registro local falló, el reintento
+	// reutiliza la clave y MP devuelve el mismo reembolso en vez de crear otro
+	if key == "" {
+		key = fmt.Sprintf("refund-order-%d-%d", order.ID, len(previous)+1)
+	}
+	refund, err := s.issueRefund(ctx, order.ID, order.PaymentID, amount, key, reason, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.markRefunded(ctx, order, refundedStatus(order, refunded+refund.Amount), refund, reason, source); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// refundedStatus es el estado que corresponde a la orden con refunded ya reembolsado
+func refundedStatus(order *models.Order, refunded int64) models.OrderStatus {
+	if refunded >= order.Total {
+		return models.OrderStatusReembolsado
+	}
+	return models.OrderStatusReembolsoParcial
+}
+
+func (s *OrderService) markRefunded(ctx context.Context, order *models.Order, next models.OrderStatus, refund *models.Refund, reason string, source models.OrderStatusSource) error {
+	err := s.OrderRepo.UpdateStatus(ctx, order.ID, next, models.StatusChange{
+		Source:    source,
+		PaymentID: order.PaymentID,
+		Reason:    fmt.Sprintf("%s (%d)", reason, refund.Amount),
+		EventType: models.OrderEventRefunded,
+	})
+	if err != nil {
+		log.Printf("[ERROR-CRITICO] Reembolso %s registrado pero la orden #%d no cambió a %s: %v", refund.MPRefundID, order.ID, next, err)
+		return err
+	}
+	return nil
+}
+
+// GetOrderRefunds devuelve los reembolsos registrados para la orden
+func (s *OrderService) GetOrderRefunds(ctx context.Context, orderID uint) ([]models.Refund, error) {
+	return s.Refunds.FindByOrderID(ctx, orderID)
+}
+
+// issueRefund pide el reembolso a Mercado Pago y lo registra. amount debe ser positivo: MP interpreta un
+// monto ausente como reembolso total. Si ya hay un reembolso registrado con idempotencyKey se devuelve ese sin volver a llamar a MP.
+func (s *OrderService) issueRefund(ctx context.Context, orderID uint, paymentID string, amount int64, idempotencyKey string, reason string, source models.OrderStatusSource) (*models.Refund, error) {
+	existing, err := s.Refunds.FindByIdempotencyKey(ctx, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("monto a reembolsar inválido (%d) para el pago #%s de la orden #%d", amount, paymentID, orderID)
+	}
+
+	details, err := s.PaymentClient.RefundPayment(ctx, paymentID, amount, idempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo reembolsar el pago #%s de la orden #%d: %w", paymentID, orderID, err)
+	}
+	if details.Amount > 0 {
+		amount = details.Amount
+	}
+
+	refund := &models.Refund{
+		OrderID:        orderID,
+		PaymentID:      paymentID,
+		MPRefundID:     details.ID,
+		Amount:         amount,
+		Status:         details.Status,
+		Source:         source,
+		Reason:         reason,
+		IdempotencyKey: idempotencyKey,
+	}
+	if err := s.Refunds.Create(ctx, refund); err != nil {
+		log.Printf("[ERROR-CRITICO] Reembolso %s del pago #%s emitido en MP pero no registrado: %v", details.ID, paymentID, err)
+		return nil, err
+	}
+
+	log.Printf("Pago #%s de la orden #%d reembolsado por %d (reembolso %s, estado %s)", paymentID, orderID, amount, details.ID, details.Status)
+	return refund, nil
+}
@@ -2,17 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
     "log"
     "strconv"
+    "strings"
 	
 	"github.com/go-redis/redis/v8"
 	"github.com/C0kke/FitFashion/ms_cart/internal/models"
 	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
 	"github.com/C0kke/FitFashion/ms_cart/pkg/database"
     "github.com/C0kke/FitFashion/ms_cart/internal/product"
-    "github.com/C0kke/FitFashion/ms_cart/internal/messaging"
     "github.com/C0kke/FitFashion/ms_cart/internal/payments"
 )
 
@@ -25,26 +26,62 @@ type OrderService struct {
     
     ProductClient product.ClientInterface
 
-    OrderPublisher *messaging.OrderPublisher
     PaymentClient payments.PaymentClient
+    Promotions *PromotionService
+    Reservations *ReservationService
+    Limits *LimitService
+    Shipping *ShippingService
+    Tax *TaxCalculator
+    Refunds repository.RefundRepository
+    Receipts *ReceiptService
+
+    // IdempotencyWindow es el tiempo durante el que una clave de idempotencia devuelve la respuesta original
+    IdempotencyWindow time.Duration
+    // PendingOrderTTL es el plazo para pagar una orden antes de que expire
+    PendingOrderTTL time.Duration
+    // ExportMaxOrders limita cuántas órdenes devuelve export_orders por RPC; los exports grandes van por cmd/export
+    ExportMaxOrders int
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, productClient product.ClientInterface, orderPublisher *messaging.OrderPublisher, paymentClient payments.PaymentClient) *OrderService {
+func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, productClient product.ClientInterface, paymentClient payments.PaymentClient, promotions *PromotionService, reservations *ReservationService, limits *LimitService, shipping *ShippingService, tax *TaxCalculator, refunds repository.RefundRepository, receipts *ReceiptService) *OrderService {
 	return &OrderService{
 		OrderRepo:   orderRepo,
 		CartRepo:    cartRepo,
 		RedisClient: database.RedisClient,
         ProductClient: productClient,
-        OrderPublisher: orderPublisher,
         PaymentClient: paymentClient,
+        Promotions: promotions,
+        Reservations: reservations,
+        Limits: limits,
+        Shipping: shipping,
+        Tax: tax,
+        Refunds: refunds,
+        Receipts: receipts,
+        IdempotencyWindow: DefaultIdempotencyWindow,
+        PendingOrderTTL: CheckoutTTL,
+        ExportMaxOrders: DefaultExportMaxOrders,
 	}
 }
 
-func (s *OrderService) ProcesarCompra(ctx context.Context, userID string, shippingAddress string) (*models.CheckoutResponse, error) {
+// ProcesarCompra crea la orden y la preferencia de pago. Con idempotencyKey, los reintentos con la misma
+// clave dentro de IdempotencyWindow devuelven la respuesta original en vez de crear otra orden.
+func (s *OrderService) ProcesarCompra(ctx context.Context, userID string, shippingAddress models.Address, idempotencyKey string) (*models.CheckoutResponse, error) {
+    if idempotencyKey == "" {
+        return s.procesarCompra(ctx, userID, shippingAddress, "")
+    }
+    return s.procesarCompraIdempotente(ctx, userID, shippingAddress, idempotencyKey)
+}
+
+func (s *OrderService) procesarCompra(ctx context.Context, userID string, shippingAddress models.Address, idempotencyKey string) (*models.CheckoutResponse, error) {
+    if err := shippingAddress.Validate(); err != nil { return nil, err }
+
     cart, err := s.CartRepo.FindByUserID(ctx, userID)
 	if err != nil { return nil, fmt.Errorf("fallo al buscar carrito: %w", err) }
     if len(cart.Items) == 0 { return nil, fmt.Errorf("el carrito está vacío") }
 
+    // Los límites se revalidan: pudieron cambiar, o el usuario tener otras órdenes, desde que armó el carrito
+    if err := s.Limits.CheckCart(ctx, userID, cart.Items); err != nil { return nil, err }
+
     cartKey := "cart:" + userID
     
     err = s.RedisClient.Expire(ctx, cartKey, CheckoutTTL).Err()
@@ -53,30 +90,84 @@ func (s *OrderService) ProcesarCompra(ctx context.Context, userID string, shippi
     userIDUint64, err := strconv.ParseUint(userID, 10, 64)
     if err != nil { return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err) }
 
-    orderItems, total, err := s.getSnapshotAndTotal(ctx, cart)
+    orderItems, subtotal, bundleSavings, err := s.getSnapshotAndTotal(ctx, cart)
     if err != nil { return nil, fmt.Errorf("fallo al obtener snapshot de productos: %w", err) }
+    total := subtotal - bundleSavings
+    expiresAt := time.Now().Add(s.PendingOrderTTL)
     
     newOrder := &models.Order{
         UserID: uint(userIDUint64),
+        Subtotal: subtotal,
+        DiscountTotal: bundleSavings,
         Total: total,
-        Status: "PENDIENTE", 
-        ShippingAddress: shippingAddress, 
+        Status: models.OrderStatusPendiente, 
+        ShippingAddress: shippingAddress.String(), 
+        ShippingDetails: shippingAddress,
+        IdempotencyKey: idempotencyKey,
+        ExpiresAt: &expiresAt,
         OrderItems: orderItems,
     }
 
-    s.OrderRepo.Create(ctx, newOrder) 
+    var appliedCoupon *models.Coupon
+    if cart.CouponCode != "" {
+        coupon, discount, err := s.Promotions.Evaluate(ctx, cart.CouponCode, userID, total)
+        if err != nil {
+            return nil, fmt.Errorf("el cupón %s ya no es aplicable: %w", cart.CouponCode, err)
+        }
+        appliedCoupon = coupon
+        newOrder.CouponCode = coupon.Code
+        newOrder.DiscountTotal += discount.Amount
+        newOrder.Total = total - discount.Amount
+    }
+    if newOrder.Total <= 0 { return nil, fmt.Errorf("el total de la orden debe ser mayor a 0") }
 
-    paymentURL, err := s.PaymentClient.StartTransaction(ctx, newOrder.ID, newOrder.Total, orderItems)
-    if err != nil {
-        return nil, fmt.Errorf("fallo al generar URL de pago en Mercado Pago: %w", err)
+    units := 0
+    for _, item := range orderItems {
+        units += item.Quantity
     }
+    quote, err := s.Shipping.Quote(shippingAddress.Region, s.Shipping.WeightFor(units), newOrder.Total)
+    if err != nil { return nil, fmt.Errorf("fallo al calcular el envío: %w", err) }
+    newOrder.ShippingCost = quote.Cost
+    newOrder.ShippingRateName = quote.RateName
+    newOrder.Total += quote.Cost
+
+    s.applyTaxBreakdown(newOrder)
 
-    go func() {
-        ctxPub := context.Background()
-        if pubErr := s.OrderPublisher.PublishOrderCreated(ctxPub, newOrder); pubErr != nil {
-            log.Printf("Error ASÍNCRONO al publicar evento de orden: %v", pubErr)
+    if err := s.OrderRepo.Create(ctx, newOrder, models.StatusChange{
+        Source: models.StatusSourceUser,
+        Reason: "checkout",
+        EventType: models.OrderEventCreated,
+    }); err != nil {
+        return nil, err
+    }
+
+    if err := s.Reservations.Reserve(ctx, newOrder); err != nil {
+        if statusErr := s.OrderRepo.UpdateStatus(ctx, newOrder.ID, models.OrderStatusStockFallido, models.StatusChange{
+            Source: models.StatusSourceSystem,
+            Reason: err.Error(),
+        }); statusErr != nil {
+            log.Printf("Advertencia: no se pudo marcar la orden #%d como STOCK_FALLIDO: %v", newOrder.ID, statusErr)
         }
-    }()
+        return nil, fmt.Errorf("no se pudo reservar el stock de la orden: %w", err)
+    }
+
+    if appliedCoupon != nil {
+        if err := s.Promotions.RecordRedemption(ctx, appliedCoupon.ID, newOrder.UserID, newOrder.ID); err != nil {
+            log.Printf("Advertencia: no se registró el uso del cupón %s en la orden #%d: %v", appliedCoupon.Code, newOrder.ID, err)
+        }
+    }
+
+    paymentURL, err := s.PaymentClient.StartTransaction(ctx, newOrder)
+    if err != nil {
+        if relErr := s.Reservations.Release(ctx, newOrder, "fallo al crear preferencia de pago"); relErr != nil {
+            log.Printf("Advertencia: %v", relErr)
+        }
+        return nil, fmt.Errorf("fallo al generar URL de pago en Mercado Pago: %w", err)
+    }
+    newOrder.PaymentURL = paymentURL
+    if err := s.OrderRepo.SetPaymentURL(ctx, newOrder.ID, paymentURL); err != nil {
+        log.Printf("Advertencia: no se guardó la URL de pago de la orden #%d: %v", newOrder.ID, err)
+    }
 
 	return &models.CheckoutResponse{
         OrderID: newOrder.ID,
@@ -85,18 +176,23 @@ func (s *OrderService) ProcesarCompra(ctx context.Context, userID string, shippi
     }, nil
 }
 
-func (s *OrderService) getSnapshotAndTotal(ctx context.Context, cart *models.Cart) ([]models.OrderItem, int64, error) {
-    productInputs := make([]product.ProductInput, len(cart.Items))
-    for i, cartItem := range cart.Items {
-        productInputs[i] = product.ProductInput{
-            ProductID: cartItem.ProductID,
-            Quantity:  cartItem.Quantity,
-        }
-    }
+// getSnapshotAndTotal devuelve los items de la orden, el subtotal de las prendas y el ahorro por outfits
+func (s *OrderService) getSnapshotAndTotal(ctx context.Context, cart *models.Cart) ([]models.OrderItem, int64, int64, error) {
+    productInputs := toProductInputs(cart.Items)
     
     calculation, err := s.ProductClient.CalculateCart(ctx, productInputs)
     if err != nil {
-        return nil, 0, fmt.Errorf("fallo RPC al obtener snapshot y total de productos: %w", err)
+        return nil, 0, 0, fmt.Errorf("fallo RPC al obtener snapshot y total de productos: %w", err)
+    }
+    calculation.AttachLineDetails(productInputs)
+
+    if changes := calculation.PriceChanges(); len(changes) > 0 {
+        return nil, 0, 0, &PricesChangedError{Changes: changes}
+    }
+
+    bundleSavings, err := applyBundlePricing(ctx, s.ProductClient, calculation)
+    if err != nil {
+        return nil, 0, 0, err
     }
 
     orderItems := make([]models.OrderItem, len(calculation.Items))
@@ -106,14 +202,22 @@ func (s *OrderService) getSnapshotAndTotal(ctx context.Context, cart *models.Car
             Quantity:     snapshotItem.Quantity,
             UnitPrice:    int64(snapshotItem.UnitPrice), // Usamos int64 para el precio CLP
             NameSnapshot: snapshotItem.NameSnapshot,
+            Size:         snapshotItem.Size,
+            Color:        snapshotItem.Color,
+            BundleID:     snapshotItem.BundleID,
         }
     }
 
-    return orderItems, int64(calculation.TotalPrice), nil
+    return orderItems, int64(calculation.TotalPrice), bundleSavings, nil
 }
 
-func (s *OrderService) GetUserOrders(ctx context.Context, userID uint) ([]models.Order, error) {
-    return s.OrderRepo.FindByUserID(ctx, userID)
+// GetUserOrders lista las órdenes del usuario; el userID del RPC manda sobre cualquier filtro del payload
+func (s *OrderService) GetUserOrders(ctx context.Context, userID uint, query models.OrderQuery) (*models.OrderPage, error) {
+    query.UserID = userID
+    if err := query.Normalize(); err != nil {
+        return nil, err
+    }
+    return s.OrderRepo.FindPage(ctx, &query)
 }
 
 func (s *OrderService) VerifyAndFinalizePayment(ctx context.Context, paymentID string) error {
@@ -130,9 +234,33 @@ func (s *OrderService) VerifyAndFinalizePayment(ctx context.Context, paymentID s
     }
 
     internalOrderID := uint(orderID)
+    change := models.StatusChange{Source: models.StatusSourceWebhook, PaymentID: paymentID, Reason: "pago " + paymentDetails.Status}
+    paidChange := change
+    paidChange.EventType = models.OrderEventPaid
 
 	if paymentDetails.Status == "approved" {
-        if err := s.OrderRepo.UpdateStatus(ctx, internalOrderID, "PAGADO"); err != nil {
+        if err := s.OrderRepo.UpdateStatus(ctx, internalOrderID, models.OrderStatusPagado, paidChange); err != nil {
+            if isRepeatedTransition(err) {
+                log.Printf("Pago #%s ya había sido aplicado a la orden #%d, se ignora", paymentID, orderID)
+                return nil
+            }
+            var invalid *models.InvalidStatusTransitionError
+            if errors.As(err, &invalid) && (invalid.From == models.OrderStatusCancelado || invalid.From == models.OrderStatusExpirado) {
+                // El cliente pagó con la preferencia de una orden que ya había cancelado o expirado.
+                // Se reembolsa exactamente lo cobrado; la clave por pago hace que un webhook repetido no duplique el registro
+                amount := paymentDetails.Amount
+                if amount <= 0 {
+                    order, findErr := s.OrderRepo.FindByID(ctx, internalOrderID)
+                    if findErr != nil {
+                        return fmt.Errorf("orden no encontrada para reembolsar el pago #%s: %w", paymentID, findErr)
+                    }
+                    amount = order.Total
+                }
+                if _, refundErr := s.issueRefund(ctx, internalOrderID, paymentID, amount, "refund-late-"+paymentID, "pago recibido sobre orden "+strings.ToLower(string(invalid.From)), models.StatusSourceSystem); refundErr != nil {
+                    return refundErr
+                }
+                return nil
+            }
             return fmt.Errorf("fallo al actualizar DB a PAGADO: %w", err)
         }
         
@@ -142,42 +270,116 @@ func (s *OrderService) VerifyAndFinalizePayment(ctx context.Context, paymentID s
             return fmt.Errorf("orden no encontrada para pago aprobado: %w", err)
         }
         
-        itemsToDecrease := make([]product.ProductInput, len(order.OrderItems)) // 🛑 Usamos OrderItems, no Items
-        for i, item := range order.OrderItems {
-            itemsToDecrease[i] = product.ProductInput{
-                ProductID: item.ProductID, 
-                Quantity:  item.Quantity,
-            }
-        }
-
-        _, rpcErr := s.ProductClient.DecreaseStock(ctx, itemsToDecrease)
-        if rpcErr != nil {
-            log.Printf("Fallo RPC al restar stock para Orden #%d: %v", orderID, rpcErr)
-            s.OrderRepo.UpdateStatus(ctx, internalOrderID, "STOCK_FALLIDO")
-            return fmt.Errorf("fallo la reducción de stock: %w", rpcErr)
+        // El stock ya se descontó al reservar; aquí solo se confirma la reserva
+        if err := s.Reservations.Commit(ctx, order); err != nil {
+            log.Printf("Fallo al confirmar reserva de stock para Orden #%d: %v", orderID, err)
+            s.OrderRepo.UpdateStatus(ctx, internalOrderID, models.OrderStatusStockFallido, models.StatusChange{
+                Source: models.StatusSourceSystem,
+                PaymentID: paymentID,
+                Reason: err.Error(),
+            })
+            return fmt.Errorf("fallo la reducción de stock: %w", err)
         }
 
         if err := s.CartRepo.DeleteByUserID(ctx, strconv.FormatUint(uint64(order.UserID), 10)); err != nil {
 			log.Printf("Advertencia: Fallo al eliminar el carrito de Redis después de pago: %v\n", err)
 		}
 
-        if pubErr := s.OrderPublisher.PublishOrderCreated(ctx, order); pubErr != nil {
-			log.Printf("Error al publicar evento de orden PAGADA: %v", pubErr)
-		}
+        // Si falla, get_order_receipt lo emite en la primera descarga
+        if _, err := s.Receipts.Issue(ctx, order); err != nil {
+            log.Printf("Advertencia: no se emitió el comprobante de la orden #%d: %v", orderID, err)
+        }
         
     } else if paymentDetails.Status == "rejected" {
-        s.OrderRepo.UpdateStatus(ctx, internalOrderID, "RECHAZADO")
+        if err := s.OrderRepo.UpdateStatus(ctx, internalOrderID, models.OrderStatusRechazado, change); err != nil {
+            if isRepeatedTransition(err) {
+                return nil
+            }
+            return fmt.Errorf("fallo al actualizar DB a RECHAZADO: %w", err)
+        }
+
+        order, err := s.OrderRepo.FindByID(ctx, internalOrderID)
+        if err != nil {
+            return fmt.Errorf("orden no encontrada para pago rechazado: %w", err)
+        }
+        if err := s.Reservations.Release(ctx, order, "pago rechazado"); err != nil {
+            log.Printf("Advertencia: %v", err)
+        }
     }
 
 	return nil
 }
 
-func (s *OrderService) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-    return s.OrderRepo.FindAll(ctx)
+func (s *OrderService) GetAllOrders(ctx context.Context, query models.OrderQuery) (*models.OrderPage, error) {
+    if err := query.Normalize(); err != nil {
+        return nil, err
+    }
+    return s.OrderRepo.FindPage(ctx, &query)
 }
 
-func (s *OrderService) UpdateStatus(ctx context.Context, orderID uint, status string) error {
-    return s.OrderRepo.UpdateStatus(ctx, orderID, status)
+// ListUserOrders devuelve todas las órdenes del usuario que cumplen query como un arreglo, el formato
+// que esperan los clientes anteriores a la paginación
+func (s *OrderService) ListUserOrders(ctx context.Context, userID uint, query models.OrderQuery) ([]models.Order, error) {
+    query.UserID = userID
+    return s.listOrders(ctx, query)
+}
+
+// ListAllOrders es ListUserOrders para el panel de administración
+func (s *OrderService) ListAllOrders(ctx context.Context, query models.OrderQuery) ([]models.Order, error) {
+    return s.listOrders(ctx, query)
+}
+
+func (s *OrderService) listOrders(ctx context.Context, query models.OrderQuery) ([]models.Order, error) {
+    query.Limit = models.MaxOrderPageSize
+    query.Cursor = ""
+    if err := query.Normalize(); err != nil {
+        return nil, err
+    }
+
+    orders := []models.Order{}
+    for {
+        page, err := s.OrderRepo.FindPage(ctx, &query)
+        if err != nil {
+            return nil, err
+        }
+        orders = append(orders, page.Orders...)
+        if !page.HasMore {
+            return orders, nil
+        }
+        query.Cursor = page.NextCursor
+    }
+}
+
+// UpdateStatus valida el estado y la transición antes de delegar en el repositorio,
+// que vuelve a comprobarla con un update condicional
+func (s *OrderService) UpdateStatus(ctx context.Context, orderID uint, status models.OrderStatus, change models.StatusChange) error {
+    if _, err := models.ParseOrderStatus(string(status)); err != nil {
+        return err
+    }
+
+    order, err := s.OrderRepo.FindByID(ctx, orderID)
+    if err != nil {
+        return fmt.Errorf("orden no encontrada: %w", err)
+    }
+    if !order.Status.CanTransitionTo(status) {
+        return &models.InvalidStatusTransitionError{OrderID: orderID, From: order.Status, To: status}
+    }
+
+    return s.OrderRepo.UpdateStatus(ctx, orderID, status, change)
+}
+
+// GetOrderHistory devuelve los cambios de estado de la orden en orden cronológico
+func (s *OrderService) GetOrderHistory(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error) {
+    if _, err := s.OrderRepo.FindByID(ctx, orderID); err != nil {
+        return nil, fmt.Errorf("orden no encontrada: %w", err)
+    }
+    return s.OrderRepo.FindStatusHistory(ctx, orderID)
+}
+
+// isRepeatedTransition detecta webhooks duplicados: la orden ya está en el estado pedido
+func isRepeatedTransition(err error) bool {
+    var invalid *models.InvalidStatusTransitionError
+    return errors.As(err, &invalid) && invalid.From == invalid.To
 }
 
 func (s *OrderService) ApproveOrder(ctx context.Context, paymentID string) error {
@@ -188,4 +390,17 @@ func (s *OrderService) ApproveOrder(ctx context.Context, paymentID string) error
     log.Printf("Procesando aprobación de orden para Payment ID: %s", paymentID)
 
     return s.VerifyAndFinalizePayment(ctx, paymentID)
-}
\ No newline at end of file
+}
+
+// applyTaxBreakdown guarda neto e IVA por línea y de la orden completa (incluye envío y descuentos)
+func (s *OrderService) applyTaxBreakdown(order *models.Order) {
+    for i := range order.OrderItems {
+        line := s.Tax.Breakdown(order.OrderItems[i].UnitPrice * int64(order.OrderItems[i].Quantity))
+        order.OrderItems[i].NetAmount = line.Net
+        order.OrderItems[i].TaxAmount = line.Tax
+    }
+    total := s.Tax.Breakdown(order.Total)
+    order.TaxRatePercent = total.RatePercent
+    order.NetTotal = total.Net
+    order.TaxTotal = total.Tax
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/messaging"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+const (
+	outboxBatchSize = 50
+	// outboxLease debe cubrir la publicación de un lote completo; si el relay muere, otro retoma los eventos al vencer
+	outboxLease      = 5 * time.Minute
+	outboxMaxBackoff = 10 * time.Minute
+)
+
+// OutboxRelay publica los eventos del outbox con confirmación del broker y reintenta con backoff los que fallan
+type OutboxRelay struct {
+	Repo      repository.OutboxRepository
+	Publisher *messaging.OrderPublisher
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, publisher *messaging.OrderPublisher) *OutboxRelay {
+	return &OutboxRelay{
+		Repo:      repo,
+		Publisher: publisher,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.RelayPending(ctx)
+			}
+		}
+	}()
+	log.Printf("Relay del outbox de eventos iniciado (cada %s)", interval)
+}
+
+// RelayPending publica lotes hasta vaciar los eventos disponibles
+func (r *OutboxRelay) RelayPending(ctx context.Context) {
+	for {
+		events, err := r.Repo.ClaimPending(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			log.Printf("Error leyendo el outbox: %v", err)
+			return
+		}
+
+		for _, event := range events {
+			err := r.Publisher.PublishConfirmed(ctx, event.EventType, strconv.FormatUint(uint64(event.ID), 10), []byte(event.Payload))
+			if err != nil {
+				next := time.Now().Add(outboxBackoff(event.Attempts + 1))
+				log.Printf("Fallo al publicar evento %s #%d (intento %d), se reintenta a las %s: %v", event.EventType, event.ID, event.Attempts+1, next.Format(time.RFC3339), err)
+				if markErr := r.Repo.MarkFailed(ctx, event.ID, err.Error(), next); markErr != nil {
+					log.Printf("Error: %v", markErr)
+				}
+				continue
+			}
+
+			// Si esto falla el evento se vuelve a publicar al vencer el lease: los consumidores deben deduplicar por message_id
+			if err := r.Repo.MarkSent(ctx, event.ID); err != nil {
+				log.Printf("Error: %v", err)
+			}
+		}
+
+		if len(events) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// outboxBackoff duplica la espera en cada intento: 2s, 4s, 8s... hasta outboxMaxBackoff
+func outboxBackoff(attempt int) time.Duration {
+	if attempt > 20 {
+		return outboxMaxBackoff
+	}
+	backoff := time.Second << uint(attempt)
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+var (
+	ErrCouponInactive    = errors.New("el cupón no está activo")
+	ErrCouponExpired     = errors.New("el cupón está expirado")
+	ErrCouponMinSpend    = errors.New("el carrito no alcanza el monto mínimo del cupón")
+	ErrCouponUsageLimit  = errors.New("el cupón alcanzó su límite de usos para este usuario")
+	ErrCouponInvalidType = errors.New("tipo de cupón inválido")
+)
+
+type PromotionService struct {
+	Repo repository.CouponRepository
+}
+
+func NewPromotionService(repo repository.CouponRepository) *PromotionService {
+	return &PromotionService{
+		Repo: repo,
+	}
+}
+
+// CreateCoupon registra un cupón nuevo validando tipo y valor
+func (s *PromotionService) CreateCoupon(ctx context.Context, coupon *models.Coupon) (*models.Coupon, error) {
+	coupon.Type = strings.ToUpper(coupon.Type)
+	switch coupon.Type {
+	case models.CouponTypePercentage:
+		if coupon.Value <= 0 || coupon.Value > 100 {
+			return nil, fmt.Errorf("el porcentaje del cupón debe estar entre 1 y 100")
+		}
+	case models.CouponTypeFixed:
+		if coupon.Value <= 0 {
+			return nil, fmt.Errorf("el monto del cupón debe ser mayor a 0")
+		}
+	default:
+		return nil, ErrCouponInvalidType
+	}
+	if strings.TrimSpace(coupon.Code) == "" {
+		return nil, fmt.Errorf("el código del cupón es obligatorio")
+	}
+
+	coupon.Active = true
+	if err := s.Repo.Create(ctx, coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// Evaluate valida el cupón contra el subtotal y el usuario, y devuelve la línea de descuento.
+// Para carritos de invitado no se valida el límite por usuario; se vuelve a comprobar en el checkout.
+func (s *PromotionService) Evaluate(ctx context.Context, code string, userID string, subtotal int64) (*models.Coupon, *models.DiscountLine, error) {
+	coupon, err := s.Repo.FindByCode(ctx, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !coupon.Active {
+		return nil, nil, ErrCouponInactive
+	}
+	if coupon.ExpiresAt != nil && time.Now().After(*coupon.ExpiresAt) {
+		return nil, nil, ErrCouponExpired
+	}
+	if subtotal < coupon.MinSpend {
+		return nil, nil, fmt.Errorf("%w (mínimo %d CLP)", ErrCouponMinSpend, coupon.MinSpend)
+	}
+
+	if coupon.MaxUsesPerUser > 0 && !models.IsGuestCartID(userID) {
+		userIDUint, err := strconv.ParseUint(userID, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ID de usuario inválido: %w", err)
+		}
+		used, err := s.Repo.CountUserRedemptions(ctx, coupon.ID, uint(userIDUint))
+		if err != nil {
+			return nil, nil, err
+		}
+		if used >= int64(coupon.MaxUsesPerUser) {
+			return nil, nil, ErrCouponUsageLimit
+		}
+	}
+
+	amount, description, err := discountAmount(coupon, subtotal)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return coupon, &models.DiscountLine{
+		Code:        coupon.Code,
+		Description: description,
+		Amount:      amount,
+	}, nil
+}
+
+// RecordRedemption asocia el uso del cupón a la orden creada
+func (s *PromotionService) RecordRedemption(ctx context.Context, couponID uint, userID uint, orderID uint) error {
+	return s.Repo.CreateRedemption(ctx, &models.CouponRedemption{
+		CouponID: couponID,
+		UserID:   userID,
+		OrderID:  orderID,
+	})
+}
+
+func discountAmount(coupon *models.Coupon, subtotal int64) (int64, string, error) {
+	var amount int64
+	var description string
+
+	switch coupon.Type {
+	case models.CouponTypePercentage:
+		amount = subtotal * coupon.Value / 100
+		description = fmt.Sprintf("%d%% de descuento", coupon.Value)
+	case models.CouponTypeFixed:
+		amount = coupon.Value
+		description = fmt.Sprintf("$%d de descuento", coupon.Value)
+	default:
+		return 0, "", ErrCouponInvalidType
+	}
+
+	if amount > subtotal {
+		amount = subtotal
+	}
+	return amount, description, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/receipts"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+const DefaultReceiptIssuer = "FitFashion"
+
+// ReceiptDocument es la respuesta de get_order_receipt; Content va en base64 cuando es PDF
+type ReceiptDocument struct {
+	OrderID     uint      `json:"order_id"`
+	Number      int64     `json:"number"`
+	IssuedAt    time.Time `json:"issued_at"`
+	Format      string    `json:"format"`
+	ContentType string    `json:"content_type"`
+	Content     string    `json:"content"`
+}
+
+// ReceiptService emite y entrega los comprobantes de órdenes pagadas
+type ReceiptService struct {
+	Repo      repository.ReceiptRepository
+	OrderRepo repository.OrderRepository
+	Issuer    string
+}
+
+func NewReceiptService(repo repository.ReceiptRepository, orderRepo repository.OrderRepository, issuer string) *ReceiptService {
+	return &ReceiptService{
+		Repo:      repo,
+		OrderRepo: orderRepo,
+		Issuer:    issuer,
+	}
+}
+
+// Issue emite el comprobante de la orden si aún no lo tiene; es idempotente
+func (s *ReceiptService) Issue(ctx context.Context, order *models.Order) (*models.Receipt, error) {
+	if order.PaymentID == "" {
+		return nil, fmt.Errorf("la orden #%d no tiene un pago aprobado", order.ID)
+	}
+
+	return s.Repo.CreateWithNextNumber(ctx, order.ID, func(receipt *models.Receipt) error {
+		receipt.IssuedAt = time.Now()
+		doc := receipts.NewDocument(s.Issuer, receipt.Number, receipt.IssuedAt, order)
+
+		html, err := receipts.RenderHTML(doc)
+		if err != nil {
+			return fmt.Errorf("error al generar el comprobante HTML: %w", err)
+		}
+		receipt.HTML = html
+		receipt.PDF = receipts.RenderPDF(doc)
+		return nil
+	})
+}
+
+// GetOrderReceipt entrega el comprobante guardado de una orden del usuario, emitiéndolo si el pago
+// se aprobó pero todavía no existe (p. ej. falló la emisión al confirmar el pago)
+func (s *ReceiptService) GetOrderReceipt(ctx context.Context, userID string, orderID uint, format string) (*ReceiptDocument, error) {
+	userIDUint64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
+	}
+
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = "pdf"
+	}
+	if format != "pdf" && format != "html" {
+		return nil, fmt.Errorf("formato de comprobante inválido: %s (usa pdf o html)", format)
+	}
+
+	order, err := s.OrderRepo.FindByID(ctx, orderID)
+	if err != nil || order.UserID != uint(userIDUint64) {
+		return nil, ErrOrderNotFound
+	}
+
+	receipt, err := s.Repo.FindByOrderID(ctx, orderID)
+	if errors.Is(err, repository.ErrReceiptNotFound) {
+		receipt, err = s.Issue(ctx, order)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ReceiptDocument{
+		OrderID:  receipt.OrderID,
+		Number:   receipt.Number,
+		IssuedAt: receipt.IssuedAt,
+		Format:   format,
+	}
+	if format == "html" {
+		doc.ContentType = "text/html; charset=utf-8"
+		doc.Content = receipt.HTML
+	} else {
+		doc.ContentType = "application/pdf"
+		doc.Content = base64.StdEncoding.EncodeToString(receipt.PDF)
+	}
+	return doc, nil
+}
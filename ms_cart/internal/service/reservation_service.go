@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// ReservationTTL mantiene el stock apartado el mismo tiempo que el carrito durante el checkout.
+// Solo aplica a órdenes sin plazo de pago propio (ExpiresAt).
+const ReservationTTL = CheckoutTTL
+
+var ErrStockUnavailable = errors.New("stock insuficiente para reservar la orden")
+
+// ReservationService descuenta stock en ms_products al crear la orden y lo devuelve
+// si el pago se rechaza o la reserva expira antes de confirmarse.
+type ReservationService struct {
+	Repo          repository.ReservationRepository
+	ProductClient product.ClientInterface
+}
+
+func NewReservationService(repo repository.ReservationRepository, productClient product.ClientInterface) *ReservationService {
+	return &ReservationService{
+		Repo:          repo,
+		ProductClient: productClient,
+	}
+}
+
+// Reserve aparta el stock de los items de la orden hasta que vence su plazo de pago, así el barrido no
+// libera stock de una orden que todavía se puede pagar
+func (s *ReservationService) Reserve(ctx context.Context, order *models.Order) error {
+	if err := s.decrease(ctx, order.OrderItems); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(ReservationTTL)
+	if order.ExpiresAt != nil {
+		expiresAt = *order.ExpiresAt
+	}
+	reservation := &models.StockReservation{
+		OrderID:   order.ID,
+		Status:    models.ReservationActive,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.Repo.Create(ctx, reservation); err != nil {
+		// Sin registro no habría forma de liberar el stock después, así que se devuelve ahora
+		if _, incErr := s.ProductClient.IncreaseStock(ctx, orderItemsToInputs(order.OrderItems)); incErr != nil {
+			log.Printf("[ERROR-CRITICO] Stock de la orden #%d descontado sin reserva registrada: %v", order.ID, incErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Release devuelve el stock de una reserva activa. Es idempotente: si ya fue liberada o confirmada no hace nada.
+func (s *ReservationService) Release(ctx context.Context, order *models.Order, reason string) error {
+	return s.releaseFrom(ctx, order, models.ReservationActive, reason)
+}
+
+// Restock devuelve el stock de la orden sea que la reserva siga activa o ya se haya confirmado
+// (orden pagada que se cancela). Si ya fue liberada no hace nada.
+func (s *ReservationService) Restock(ctx context.Context, order *models.Order, reason string) error {
+	if err := s.releaseFrom(ctx, order, models.ReservationActive, reason); err != nil {
+		return err
+	}
+	return s.releaseFrom(ctx, order, models.ReservationCommitted, reason)
+}
+
+func (s *ReservationService) releaseFrom(ctx context.Context, order *models.Order, from string, reason string) error {
+	claimed, err := s.Repo.Transition(ctx, order.ID, from, models.ReservationReleased, reason)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	output, rpcErr := s.ProductClient.IncreaseStock(ctx, orderItemsToInputs(order.OrderItems))
+	if rpcErr == nil && !output.Success {
+		rpcErr = errors.New(output.Message)
+	}
+	if rpcErr != nil {
+		// Se vuelve al estado anterior; si era ACTIVA el barrido reintenta la devolución
+		if _, revertErr := s.Repo.Transition(ctx, order.ID, models.ReservationReleased, from, ""); revertErr != nil {
+			log.Printf("[ERROR-CRITICO] No se pudo revertir la reserva de la orden #%d: %v", order.ID, revertErr)
+		}
+		return fmt.Errorf("fallo al devolver stock de la orden #%d: %w", order.ID, rpcErr)
+	}
+
+	log.Printf("Reserva de stock de la orden #%d liberada (%s)", order.ID, reason)
+	return nil
+}
+
+// Commit confirma la reserva al aprobarse el pago. Si la reserva ya había expirado,
+// se intenta descontar el stock nuevamente.
+func (s *ReservationService) Commit(ctx context.Context, order *models.Order) error {
+	claimed, err := s.Repo.Transition(ctx, order.ID, models.ReservationActive, models.ReservationCommitted, "")
+	if err != nil {
+		return err
+	}
+	if claimed {
+		return nil
+	}
+
+	reservation, err := s.Repo.FindByOrderID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	if reservation.Status == models.ReservationCommitted {
+		return nil
+	}
+
+	// Reserva liberada (expiró antes del pago): hay que volver a descontar
+	if err := s.decrease(ctx, order.OrderItems); err != nil {
+		return err
+	}
+	if _, err := s.Repo.Transition(ctx, order.ID, models.ReservationReleased, models.ReservationCommitted, ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RestockItems devuelve a ms_products el stock de prendas que vuelven fuera de una reserva (devoluciones)
+func (s *ReservationService) RestockItems(ctx context.Context, items []models.OrderItem) error {
+	output, rpcErr := s.ProductClient.IncreaseStock(ctx, orderItemsToInputs(items))
+	if rpcErr == nil && !output.Success {
+		rpcErr = errors.New(output.Message)
+	}
+	if rpcErr != nil {
+		return fmt.Errorf("fallo al devolver stock a ms_products: %w", rpcErr)
+	}
+	return nil
+}
+
+// StartExpirySweeper libera periódicamente las reservas vencidas
+func (s *ReservationService) StartExpirySweeper(ctx context.Context, interval time.Duration, orderRepo repository.OrderRepository) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.releaseExpired(ctx, orderRepo)
+			}
+		}
+	}()
+	log.Printf("Barrido de reservas de stock iniciado (cada %s)", interval)
+}
+
+func (s *ReservationService) releaseExpired(ctx context.Context, orderRepo repository.OrderRepository) {
+	expired, err := s.Repo.FindExpired(ctx, time.Now(), 100)
+	if err != nil {
+		log.Printf("Error buscando reservas vencidas: %v", err)
+		return
+	}
+
+	for _, reservation := range expired {
+		order, err := orderRepo.FindByID(ctx, reservation.OrderID)
+		if err != nil {
+			log.Printf("Error cargando orden #%d de reserva vencida: %v", reservation.OrderID, err)
+			continue
+		}
+		if err := s.Release(ctx, order, "reserva expirada"); err != nil {
+			log.Printf("Error liberando reserva vencida: %v", err)
+		}
+	}
+}
+
+func (s *ReservationService) decrease(ctx context.Context, items []models.OrderItem) error {
+	output, rpcErr := s.ProductClient.DecreaseStock(ctx, orderItemsToInputs(items))
+	if rpcErr != nil {
+		return fmt.Errorf("fallo RPC al reservar stock con ms_products: %w", rpcErr)
+	}
+	if !output.Success {
+		return fmt.Errorf("%w: %s", ErrStockUnavailable, output.Message)
+	}
+	return nil
+}
+
+func orderItemsToInputs(items []models.OrderItem) []product.ProductInput {
+	inputs := make([]product.ProductInput, len(items))
+	for i, item := range items {
+		inputs[i] = product.ProductInput{
+			ProductID: item.ProductID,
+			Size:      item.Size,
+			Color:     item.Color,
+			Quantity:  item.Quantity,
+		}
+	}
+	return inputs
+}
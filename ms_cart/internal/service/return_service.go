@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// ReturnService gestiona las devoluciones (RMA): el cliente las solicita, el admin las aprueba y las recibe.
+// Al recibirlas se devuelve el stock y se reembolsa o se crea la orden de cambio.
+type ReturnService struct {
+	Repo   repository.ReturnRepository
+	Orders *OrderService
+}
+
+func NewReturnService(repo repository.ReturnRepository, orders *OrderService) *ReturnService {
+	return &ReturnService{
+		Repo:   repo,
+		Orders: orders,
+	}
+}
+
+// RequestReturn abre una devolución sobre items de una orden entregada del usuario
+func (s *ReturnService) RequestReturn(ctx context.Context, userID string, orderID uint, resolution string, reason string, items []models.ReturnItem) (*models.ReturnRequest, error) {
+	userIDUint64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
+	}
+
+	resolution = strings.ToUpper(strings.TrimSpace(resolution))
+	if resolution == "" {
+		resolution = models.ReturnResolutionRefund
+	}
+	if resolution != models.ReturnResolutionRefund && resolution != models.ReturnResolutionExchange {
+		return nil, fmt.Errorf("resolución inválida: %s (usa REEMBOLSO o CAMBIO)", resolution)
+	}
+
+	ret := &models.ReturnRequest{
+		OrderID:    orderID,
+		UserID:     uint(userIDUint64),
+		Status:     models.ReturnRequested,
+		Resolution: resolution,
+		Reason:     strings.TrimSpace(reason),
+		Items:      items,
+	}
+	if err := s.Repo.CreateForOrder(ctx, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetUserReturns lista las devoluciones del usuario
+func (s *ReturnService) GetUserReturns(ctx context.Context, userID string) ([]models.ReturnRequest, error) {
+	userIDUint64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
+	}
+	return s.Repo.FindByUserID(ctx, uint(userIDUint64))
+}
+
+// GetOrderReturns lista las devoluciones de una orden (soporte)
+func (s *ReturnService) GetOrderReturns(ctx context.Context, orderID uint) ([]models.ReturnRequest, error) {
+	return s.Repo.FindByOrderID(ctx, orderID)
+}
+
+func (s *ReturnService) ApproveReturn(ctx context.Context, returnID uint, note string) (*models.ReturnRequest, error) {
+	return s.review(ctx, returnID, models.ReturnApproved, models.ReturnEventApproved, note)
+}
+
+func (s *ReturnService) RejectReturn(ctx context.Context, returnID uint, note string) (*models.ReturnRequest, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, fmt.Errorf("indica el motivo del rechazo")
+	}
+	return s.review(ctx, returnID, models.ReturnRejected, models.ReturnEventRejected, note)
+}
+
+func (s *ReturnService) review(ctx context.Context, returnID uint, to string, eventType string, note string) (*models.ReturnRequest, error) {
+	ret, err := s.Repo.FindByID(ctx, returnID)
+	if err != nil {
+		return nil, err
+	}
+	from := ret.Status
+	if !models.ReturnCanTransition(from, to) {
+		return nil, fmt.Errorf("transición de devolución inválida: %s -> %s", from, to)
+	}
+
+	ret.Status = to
+	ret.AdminNote = strings.TrimSpace(note)
+	if to == models.ReturnApproved {
+		now := time.Now()
+		ret.ApprovedAt = &now
+	}
+	if err := s.transition(ctx, ret, from, eventType); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ReceiveReturn registra la llegada de las prendas, devuelve el stock y resuelve la devolución.
+// Si la resolución falla la devolución queda RECIBIDA y se puede volver a llamar para reintentarla.
+func (s *ReturnService) ReceiveReturn(ctx context.Context, returnID uint) (*models.ReturnRequest, error) {
+	ret, err := s.Repo.FindByID(ctx, returnID)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.Orders.OrderRepo.FindByID(ctx, ret.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("orden de la devolución no encontrada: %w", err)
+	}
+	returned, err := returnedOrderItems(order, ret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ret.Status {
+	case models.ReturnApproved:
+		now := time.Now()
+		ret.Status = models.ReturnReceived
+		ret.ReceivedAt = &now
+		if err := s.transition(ctx, ret, models.ReturnApproved, models.ReturnEventReceived); err != nil {
+			return nil, err
+		}
+		if err := s.Orders.Reservations.RestockItems(ctx, returned); err != nil {
+			// La devolución ya quedó recibida: el stock se corrige a mano, pero no se bloquea el reembolso
+			log.Printf("[ERROR-CRITICO] Devolución #%d recibida sin devolver su stock: %v", ret.ID, err)
+		}
+	case models.ReturnReceived:
+		// Reintento de una resolución que falló
+	default:
+		return nil, fmt.Errorf("transición de devolución inválida: %s -> %s", ret.Status, models.ReturnReceived)
+	}
+
+	// La orden de cambio se enlaza a la devolución en la misma transacción que la crea y el reembolso usa
+	// una clave propia de la devolución, así un reintento retoma lo ya hecho en vez de duplicarlo
+	if ret.Resolution == models.ReturnResolutionExchange {
+		exchange, change := s.Orders.NewExchangeOrder(order, ret, returned)
+		if _, err := s.Repo.CreateExchangeOrder(ctx, ret, exchange, change); err != nil {
+			return nil, err
+		}
+		if err := s.Orders.FulfillExchangeOrder(ctx, exchange); err != nil {
+			return nil, err
+		}
+	} else if ret.RefundID == nil {
+		key := fmt.Sprintf("return-%d-refund", ret.ID)
+		refund, err := s.Orders.refundOrder(ctx, order.ID, returnRefundAmount(order, returned), fmt.Sprintf("devolución #%d", ret.ID), models.StatusSourceAdmin, key)
+		if err != nil {
+			return nil, err
+		}
+		ret.RefundID = &refund.ID
+		if err := s.transition(ctx, ret, models.ReturnReceived, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	ret.Status = models.ReturnCompleted
+	ret.CompletedAt = &now
+	if err := s.transition(ctx, ret, models.ReturnReceived, models.ReturnEventCompleted); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *ReturnService) transition(ctx context.Context, ret *models.ReturnRequest, from string, eventType string) error {
+	claimed, err := s.Repo.Transition(ctx, ret, from, eventType)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.New("la devolución cambió concurrentemente, vuelve a intentarlo")
+	}
+	return nil
+}
+
+// returnedOrderItems arma las líneas devueltas con los datos de la orden y la cantidad de la devolución
+func returnedOrderItems(order *models.Order, ret *models.ReturnRequest) ([]models.OrderItem, error) {
+	byID := make(map[uint]models.OrderItem, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		byID[item.ID] = item
+	}
+
+	items := make([]models.OrderItem, 0, len(ret.Items))
+	for _, returned := range ret.Items {
+		item, ok := byID[returned.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("el item %d de la devolución #%d no existe en la orden", returned.OrderItemID, ret.ID)
+		}
+		item.Quantity = returned.Quantity
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// returnRefundAmount calcula lo pagado por las prendas devueltas: el valor de lista se escala por lo que
+// efectivamente se pagó por los productos (sin envío, con descuentos). El envío no se reembolsa.
+func returnRefundAmount(order *models.Order, returned []models.OrderItem) int64 {
+	var listValue int64
+	for _, item := range returned {
+		listValue += item.UnitPrice * int64(item.Quantity)
+	}
+	paidForItems := order.Total - order.ShippingCost
+	if order.Subtotal <= 0 || paidForItems >= order.Subtotal {
+		return listValue
+	}
+	return listValue * paidForItems / order.Subtotal
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/repository"
+)
+
+// ShipmentUpdate son los cambios que el admin puede aplicar a un despacho; los campos nil no se tocan
+type ShipmentUpdate struct {
+	Carrier        *string `json:"carrier"`
+	TrackingNumber *string `json:"tracking_number"`
+	Status         string  `json:"status"`
+}
+
+// shipmentTransitions define el avance permitido de un despacho; nunca retrocede
+var shipmentTransitions = map[string][]string{
+	models.ShipmentPreparing: {models.ShipmentShipped, models.ShipmentDelivered},
+	models.ShipmentShipped:   {models.ShipmentDelivered},
+}
+
+// ShipmentService gestiona los despachos de órdenes pagadas y mantiene la orden en ENVIADO/ENTREGADO
+type ShipmentService struct {
+	Repo      repository.ShipmentRepository
+	OrderRepo repository.OrderRepository
+}
+
+func NewShipmentService(repo repository.ShipmentRepository, orderRepo repository.OrderRepository) *ShipmentService {
+	return &ShipmentService{
+		Repo:      repo,
+		OrderRepo: orderRepo,
+	}
+}
+
+// CreateShipment crea un despacho en PREPARANDO. Sin items, lleva todas las unidades aún no despachadas.
+func (s *ShipmentService) CreateShipment(ctx context.Context, orderID uint, carrier string, trackingNumber string, items []models.ShipmentItem) (*models.Shipment, error) {
+	shipment := &models.Shipment{
+		OrderID:        orderID,
+		Carrier:        strings.TrimSpace(carrier),
+		TrackingNumber: strings.TrimSpace(trackingNumber),
+		Status:         models.ShipmentPreparing,
+		Items:          items,
+	}
+	if err := s.Repo.CreateForOrder(ctx, shipment); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// UpdateShipment corrige carrier/tracking y avanza el estado del despacho. Al salir un despacho se publica
+// order.shipped; la orden pasa a ENVIADO con el primero y a ENTREGADO cuando todo fue entregado.
+func (s *ShipmentService) UpdateShipment(ctx context.Context, shipmentID uint, update ShipmentUpdate) (*models.Shipment, error) {
+	shipment, err := s.Repo.FindByID(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	fromStatus := shipment.Status
+
+	if update.Carrier != nil {
+		shipment.Carrier = strings.TrimSpace(*update.Carrier)
+	}
+	if update.TrackingNumber != nil {
+		shipment.TrackingNumber = strings.TrimSpace(*update.TrackingNumber)
+	}
+
+	var eventType string
+	status := strings.ToUpper(strings.TrimSpace(update.Status))
+	if status != "" && status != fromStatus {
+		if !shipmentCanTransition(fromStatus, status) {
+			return nil, fmt.Errorf("transición de despacho inválida: %s -> %s", fromStatus, status)
+		}
+
+		now := time.Now()
+		if fromStatus == models.ShipmentPreparing {
+			if shipment.Carrier == "" || shipment.TrackingNumber == "" {
+				return nil, fmt.Errorf("el despacho #%d necesita carrier y número de seguimiento antes de enviarse", shipment.ID)
+			}
+			shipment.ShippedAt = &now
+			eventType = models.OrderEventShipped
+		}
+		if status == models.ShipmentDelivered {
+			shipment.DeliveredAt = &now
+		}
+		shipment.Status = status
+	}
+
+	change := models.StatusChange{
+		Source: models.StatusSourceAdmin,
+		Reason: fmt.Sprintf("despacho #%d %s %s", shipment.ID, shipment.Carrier, shipment.TrackingNumber),
+	}
+	if _, err := s.Repo.SaveAndSyncOrder(ctx, shipment, fromStatus, eventType, change); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// GetOrderShipments devuelve los despachos de una orden del usuario
+func (s *ShipmentService) GetOrderShipments(ctx context.Context, userID string, orderID uint) ([]models.Shipment, error) {
+	userIDUint64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID de usuario RPC inválido: %w", err)
+	}
+
+	order, err := s.OrderRepo.FindByID(ctx, orderID)
+	if err != nil || order.UserID != uint(userIDUint64) {
+		return nil, ErrOrderNotFound
+	}
+	return s.Repo.FindByOrderID(ctx, orderID)
+}
+
+func shipmentCanTransition(from, to string) bool {
+	for _, allowed := range shipmentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
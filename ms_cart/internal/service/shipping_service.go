@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+)
+
+// ShippingRate es una fila de la tabla de tarifas. Region "*" aplica a cualquier región sin tarifa propia.
+type ShippingRate struct {
+	Region         string `json:"region"`
+	Name           string `json:"name"`
+	MaxWeightGrams int    `json:"max_weight_grams"` // 0 = sin tope de peso
+	Price          int64  `json:"price"`
+	FreeOverValue  int64  `json:"free_over_value"` // 0 = nunca gratis
+}
+
+type ShippingQuote struct {
+	Region       string `json:"region"`
+	RateName     string `json:"rate_name"`
+	WeightGrams  int    `json:"weight_grams"`
+	CartValue    int64  `json:"cart_value"`
+	Cost         int64  `json:"cost"`
+	FreeShipping bool   `json:"free_shipping"`
+}
+
+var defaultShippingRates = []ShippingRate{
+	{Region: "METROPOLITANA", Name: "Envío RM", MaxWeightGrams: 3000, Price: 3990, FreeOverValue: 50000},
+	{Region: "METROPOLITANA", Name: "Envío RM (sobredimensionado)", Price: 6990, FreeOverValue: 50000},
+	{Region: "*", Name: "Envío regiones", MaxWeightGrams: 3000, Price: 5990, FreeOverValue: 80000},
+	{Region: "*", Name: "Envío regiones (sobredimensionado)", Price: 9990, FreeOverValue: 80000},
+}
+
+type ShippingService struct {
+	Rates           []ShippingRate
+	ItemWeightGrams int
+	CartService     *CartService
+}
+
+func NewShippingService(rates []ShippingRate, itemWeightGrams int, cartService *CartService) *ShippingService {
+	return &ShippingService{
+		Rates:           rates,
+		ItemWeightGrams: itemWeightGrams,
+		CartService:     cartService,
+	}
+}
+
+// LoadShippingRatesFromEnv lee la tabla desde el JSON en SHIPPING_RATES_FILE, o usa la tabla por defecto
+func LoadShippingRatesFromEnv() []ShippingRate {
+	path := os.Getenv("SHIPPING_RATES_FILE")
+	if path == "" {
+		return defaultShippingRates
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Advertencia: no se pudo leer SHIPPING_RATES_FILE (%v), usando tarifas por defecto", err)
+		return defaultShippingRates
+	}
+	var rates []ShippingRate
+	if err := json.Unmarshal(raw, &rates); err != nil || len(rates) == 0 {
+		log.Printf("Advertencia: SHIPPING_RATES_FILE inválido (%v), usando tarifas por defecto", err)
+		return defaultShippingRates
+	}
+	return rates
+}
+
+// Quote calcula el costo de envío para una región, peso y valor de carrito
+func (s *ShippingService) Quote(region string, weightGrams int, cartValue int64) (*ShippingQuote, error) {
+	normalized := normalizeRegion(region)
+
+	candidates := s.ratesFor(normalized)
+	if len(candidates) == 0 {
+		candidates = s.ratesFor("*")
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no hay tarifas de envío para la región %s", region)
+	}
+
+	// La tarifa más ajustada al peso: menor tope que lo cubra; 0 (sin tope) al final
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].MaxWeightGrams, candidates[j].MaxWeightGrams
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+
+	for _, rate := range candidates {
+		if rate.MaxWeightGrams != 0 && weightGrams > rate.MaxWeightGrams {
+			continue
+		}
+		quote := &ShippingQuote{
+			Region:      normalized,
+			RateName:    rate.Name,
+			WeightGrams: weightGrams,
+			CartValue:   cartValue,
+			Cost:        rate.Price,
+		}
+		if rate.FreeOverValue > 0 && cartValue >= rate.FreeOverValue {
+			quote.Cost = 0
+			quote.FreeShipping = true
+		}
+		return quote, nil
+	}
+	return nil, fmt.Errorf("ninguna tarifa de envío cubre %d gramos para la región %s", weightGrams, region)
+}
+
+// QuoteCart cotiza el envío del carrito actual hacia la dirección indicada
+func (s *ShippingService) QuoteCart(ctx context.Context, userID string, address models.Address) (*ShippingQuote, error) {
+	if strings.TrimSpace(address.Region) == "" {
+		return nil, fmt.Errorf("la región es obligatoria para cotizar el envío")
+	}
+
+	priced, err := s.CartService.GetCartWithPrices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(priced.Items) == 0 {
+		return nil, fmt.Errorf("el carrito está vacío")
+	}
+
+	units := 0
+	for _, item := range priced.Items {
+		units += item.Quantity
+	}
+	return s.Quote(address.Region, s.WeightFor(units), int64(priced.TotalPrice))
+}
+
+// WeightFor estima el peso del paquete; ms_products no guarda pesos, así que se usa un peso por unidad
+func (s *ShippingService) WeightFor(units int) int {
+	return units * s.ItemWeightGrams
+}
+
+func (s *ShippingService) ratesFor(region string) []ShippingRate {
+	var rates []ShippingRate
+	for _, rate := range s.Rates {
+		if normalizeRegion(rate.Region) == region {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
+
+func normalizeRegion(region string) string {
+	r := strings.ToUpper(strings.TrimSpace(region))
+	r = strings.TrimPrefix(r, "REGIÓN ")
+	r = strings.TrimPrefix(r, "REGION ")
+	r = strings.TrimPrefix(r, "DE ")
+	r = strings.TrimPrefix(r, "DEL ")
+	if r == "RM" || r == "METROPOLITANA DE SANTIAGO" || r == "SANTIAGO" {
+		return "METROPOLITANA"
+	}
+	return r
+}
@@ -0,0 +1,27 @@
+package service
+
+import "github.com/C0kke/FitFashion/ms_cart/internal/models"
+
+// DefaultTaxRatePercent es el IVA vigente en Chile
+const DefaultTaxRatePercent = 19
+
+// TaxCalculator desglosa precios CLP con IVA incluido
+type TaxCalculator struct {
+	RatePercent int64
+}
+
+func NewTaxCalculator(ratePercent int64) *TaxCalculator {
+	return &TaxCalculator{RatePercent: ratePercent}
+}
+
+// Breakdown calcula neto e IVA de un monto bruto, redondeando el neto al peso más cercano
+func (t *TaxCalculator) Breakdown(gross int64) models.TaxBreakdown {
+	divisor := 100 + t.RatePercent
+	net := (gross*100 + divisor/2) / divisor
+	return models.TaxBreakdown{
+		RatePercent: t.RatePercent,
+		Net:         net,
+		Tax:         gross - net,
+		Gross:       gross,
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/C0kke/FitFashion/ms_cart/internal/models"
+	"github.com/C0kke/FitFashion/ms_cart/internal/product"
+)
+
+func TestTaxCalculatorBreakdown(t *testing.T) {
+	tax := NewTaxCalculator(DefaultTaxRatePercent)
+
+	cases := []struct {
+		gross, net, tax int64
+	}{
+		{11900, 10000, 1900},
+		{1000, 840, 160}, // 840,34 se redondea a 840
+		{1, 1, 0},
+		{0, 0, 0},
+	}
+	for _, c := range cases {
+		got := tax.Breakdown(c.gross)
+		if got.Net != c.net || got.Tax != c.tax || got.Gross != c.gross || got.RatePercent != DefaultTaxRatePercent {
+			t.Errorf("Breakdown(%d) = %+v, want neto %d e IVA %d", c.gross, got, c.net, c.tax)
+		}
+		if got.Net+got.Tax != c.gross {
+			t.Errorf("Breakdown(%d): neto + IVA no suma el bruto", c.gross)
+		}
+	}
+
+	if got := NewTaxCalculator(0).Breakdown(5000); got.Net != 5000 || got.Tax != 0 {
+		t.Errorf("sin IVA el neto es el bruto, got %+v", got)
+	}
+}
+
+func TestOrderServiceApplyTaxBreakdown(t *testing.T) {
+	s := &OrderService{Tax: NewTaxCalculator(DefaultTaxRatePercent)}
+	order := &models.Order{
+		Total: 14280, // 11900 de productos + 2380 de envío
+		OrderItems: []models.OrderItem{
+			{UnitPrice: 5950, Quantity: 2},
+		},
+	}
+
+	s.applyTaxBreakdown(order)
+
+	if order.NetTotal != 12000 || order.TaxTotal != 2280 || order.TaxRatePercent != DefaultTaxRatePercent {
+		t.Errorf("total: got neto %d IVA %d tasa %d", order.NetTotal, order.TaxTotal, order.TaxRatePercent)
+	}
+	if item := order.OrderItems[0]; item.NetAmount != 10000 || item.TaxAmount != 1900 {
+		t.Errorf("línea: got neto %d IVA %d", item.NetAmount, item.TaxAmount)
+	}
+}
+
+func TestCartServiceApplyTaxBreakdown(t *testing.T) {
+	calculation := &product.CartCalculationOutput{
+		TotalPrice: 11900,
+		Items:      []product.CartItemSnapshot{{Subtotal: 11900}},
+	}
+
+	(&CartService{}).applyTaxBreakdown(calculation)
+	if calculation.Tax != nil {
+		t.Fatal("sin calculadora no se agrega desglose")
+	}
+
+	(&CartService{Tax: NewTaxCalculator(DefaultTaxRatePercent)}).applyTaxBreakdown(calculation)
+	if calculation.Tax == nil || calculation.Tax.Net != 10000 || calculation.Tax.Tax != 1900 {
+		t.Fatalf("total: got %+v", calculation.Tax)
+	}
+	if item := calculation.Items[0]; item.NetSubtotal != 10000 || item.TaxAmount != 1900 {
+		t.Errorf("línea: got neto %d IVA %d", item.NetSubtotal, item.TaxAmount)
+	}
+}
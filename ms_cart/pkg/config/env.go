@@ -0,0 +1,36 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Int lee un entero no negativo desde el entorno; si falta o es inválido devuelve fallback
+func Int(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		log.Printf("Advertencia: %s inválido (%q), usando %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// Duration lee una duración tipo "30m" o "24h" desde el entorno
+func Duration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Advertencia: %s inválido (%q), usando %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
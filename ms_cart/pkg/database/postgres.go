@@ -32,10 +32,14 @@ func ConectarPostgres() {
 
 	fmt.Println("Conexión exitosa a PostgreSQL")
 
-	err = db.AutoMigrate(&models.Order{}, &models.OrderItem{})
+	err = db.AutoMigrate(&models.Order{}, &models.OrderItem{}, &models.Coupon{}, &models.CouponRedemption{}, &models.StockReservation{}, &models.ProductPurchaseLimit{}, &models.OrderStatusHistory{}, &models.OutboxEvent{}, &models.Refund{}, &models.Shipment{}, &models.ShipmentItem{}, &models.ReturnRequest{}, &models.ReturnItem{}, &models.Receipt{})
 	if err != nil {
 		log.Fatalf("Fallo la migración de la DB: %v", err)
 	}
+	// Las órdenes antiguas quedaron con el default "PENDING", que no existe en la máquina de estados
+	if err := db.Model(&models.Order{}).Where("status = ?", "PENDING").Update("status", models.OrderStatusPendiente).Error; err != nil {
+		log.Fatalf("Fallo la normalización de estados de órdenes: %v", err)
+	}
 	fmt.Println("Migraciones de PostgreSQL completadas.")
 
 	DB = db
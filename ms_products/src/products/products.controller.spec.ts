@@ -20,6 +20,7 @@ describe('ProductsController', () => {
     validateStock: jest.fn().mockResolvedValue({ valid: true }),
     calculateCartDetails: jest.fn().mockResolvedValue({ totalPrice: 100 }),
     decreaseStockBatch: jest.fn().mockResolvedValue({ success: true }),
+    increaseStockBatch: jest.fn().mockResolvedValue({ success: true }),
   };
 
   // 2. MOCK DEL CONTEXTO DE RABBITMQ (Para probar replyManual)
@@ -117,5 +118,10 @@ describe('ProductsController', () => {
       await controller.handleDecreaseStock([], mockRmqContext);
       expect(service.decreaseStockBatch).toHaveBeenCalled();
     });
+
+    it('handleIncreaseStock debería procesar', async () => {
+      await controller.handleIncreaseStock([], mockRmqContext);
+      expect(service.increaseStockBatch).toHaveBeenCalled();
+    });
   });
 });
\ No newline at end of file
@@ -117,7 +117,16 @@ export class ProductsController {
     });
   }
 
-  // 6. Calcular Outfit Maniquí
+  // 6. Devolver Stock (Reserva liberada / Cancelación)
+  @MessagePattern('increase_stock')
+  async handleIncreaseStock(@Payload() data: CartItemDto[], @Ctx() context: RmqContext) {
+    await this.replyManual(context, async () => {
+      console.log('[RabbitMQ] Devolviendo stock...');
+      return await this.productsService.increaseStockBatch(data);
+    });
+  }
+
+  // 7. Calcular Outfit Maniquí
   @MessagePattern('calculate_outfit_price')
   async handleCalculateOutfit(@Payload() data: string[], @Ctx() context: RmqContext) {
     await this.replyManual(context, async () => {
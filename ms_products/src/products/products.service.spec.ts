@@ -26,8 +26,19 @@ describe('ProductsService', () => {
       where: jest.fn().mockReturnThis(),
       getMany: jest.fn().mockResolvedValue([]),
     })),
+    // La transacción ejecuta el callback con un EntityManager simulado
+    manager: {
+      transaction: jest.fn(),
+    },
   };
 
+  const mockEntityManager = {
+    decrement: jest.fn(),
+    increment: jest.fn(),
+    findOneBy: jest.fn(),
+  };
+  mockProductRepository.manager.transaction.mockImplementation((work) => work(mockEntityManager));
+
   const mockCloudinaryService = {
     uploadImage: jest.fn().mockResolvedValue({ secure_url: 'https://fake.com/img.png' }),
   };
@@ -93,6 +104,21 @@ describe('ProductsService', () => {
       expect(result.valid).toBe(false);
     });
 
+    it('debería informar el stock disponible por item', async () => {
+      productRepo.findBy.mockResolvedValue([{ id: '1', name: 'A', stock: 3 }, { id: '2', name: 'B', stock: 10 }]);
+      const result = await service.validateStock([
+        { productId: '1', quantity: 5 },
+        { productId: '2', quantity: 1 },
+        { productId: '3', quantity: 1 },
+      ]);
+      expect(result.valid).toBe(false);
+      expect(result.items).toEqual([
+        { productId: '1', requested: 5, available: 3, found: true, valid: false },
+        { productId: '2', requested: 1, available: 10, found: true, valid: true },
+        { productId: '3', requested: 1, available: 0, found: false, valid: false },
+      ]);
+    });
+
     it('debería validar false si lista vacía', async () => {
         const result = await service.validateStock([]);
         expect(result.valid).toBe(false);
@@ -156,11 +182,13 @@ describe('ProductsService', () => {
 
   // --- 7. DECREASE STOCK BATCH (Cubre el checkout) ---
   describe('decreaseStockBatch', () => {
-    it('debería restar stock de múltiples productos', async () => {
-        mockProductRepository.findBy.mockResolvedValue([
-            { id: '1', name: 'A', stock: 10 },
-            { id: '2', name: 'B', stock: 10 }
-        ]);
+    beforeEach(() => {
+        mockEntityManager.decrement.mockReset();
+        mockEntityManager.findOneBy.mockReset();
+    });
+
+    it('debería restar stock de múltiples productos con descuentos condicionales', async () => {
+        mockEntityManager.decrement.mockResolvedValue({ affected: 1 });
 
         const items = [
             { productId: '1', quantity: 5 },
@@ -170,22 +198,66 @@ describe('ProductsService', () => {
         const result = await service.decreaseStockBatch(items);
 
         expect(result.success).toBe(true);
-        // Verificamos que save se llamó con los stocks actualizados
-        expect(productRepo.save).toHaveBeenCalledWith(expect.arrayContaining([
-            expect.objectContaining({ id: '1', stock: 5 }),
-            expect.objectContaining({ id: '2', stock: 5 })
-        ]));
+        expect(mockEntityManager.decrement).toHaveBeenCalledWith(Product, expect.objectContaining({ id: '1' }), 'stock', 5);
+        expect(mockEntityManager.decrement).toHaveBeenCalledWith(Product, expect.objectContaining({ id: '2' }), 'stock', 5);
     });
 
-    it('debería fallar si alguno no tiene stock en el lote', async () => {
-        mockProductRepository.findBy.mockResolvedValue([
-            { id: '1', name: 'A', stock: 1 } // Solo 1 disponible
+    it('debería sumar las variantes de un mismo producto en un solo descuento', async () => {
+        mockEntityManager.decrement.mockResolvedValue({ affected: 1 });
+
+        await service.decreaseStockBatch([
+            { productId: '1', quantity: 2 },
+            { productId: '1', quantity: 3 }
         ]);
 
+        expect(mockEntityManager.decrement).toHaveBeenCalledTimes(1);
+        expect(mockEntityManager.decrement).toHaveBeenCalledWith(Product, expect.objectContaining({ id: '1' }), 'stock', 5);
+    });
+
+    it('debería fallar si alguno no tiene stock en el lote', async () => {
+        // El descuento condicional no afecta filas: otro checkout se llevó las unidades
+        mockEntityManager.decrement.mockResolvedValue({ affected: 0 });
+        mockEntityManager.findOneBy.mockResolvedValue({ id: '1', name: 'A', stock: 1 });
+
         const items = [{ productId: '1', quantity: 5 }]; // Pido 5
 
         await expect(service.decreaseStockBatch(items)).rejects.toThrow(BadRequestException);
     });
+
+    it('debería fallar si el producto no existe', async () => {
+        mockEntityManager.decrement.mockResolvedValue({ affected: 0 });
+        mockEntityManager.findOneBy.mockResolvedValue(null);
+
+        await expect(service.decreaseStockBatch([{ productId: '9', quantity: 1 }])).rejects.toThrow(NotFoundException);
+    });
+  });
+
+  // --- 7b. INCREASE STOCK BATCH (Reservas liberadas) ---
+  describe('increaseStockBatch', () => {
+    beforeEach(() => {
+        mockEntityManager.increment.mockReset();
+    });
+
+    it('debería sumar stock a múltiples productos', async () => {
+        mockEntityManager.increment.mockResolvedValue({ affected: 1 });
+
+        const items = [
+            { productId: '1', quantity: 3 },
+            { productId: '2', quantity: 1 }
+        ];
+
+        const result = await service.increaseStockBatch(items);
+
+        expect(result.success).toBe(true);
+        expect(mockEntityManager.increment).toHaveBeenCalledWith(Product, { id: '1' }, 'stock', 3);
+        expect(mockEntityManager.increment).toHaveBeenCalledWith(Product, { id: '2' }, 'stock', 1);
+    });
+
+    it('debería fallar si el producto no existe', async () => {
+        mockEntityManager.increment.mockResolvedValue({ affected: 0 });
+
+        await expect(service.increaseStockBatch([{ productId: '9', quantity: 1 }])).rejects.toThrow(NotFoundException);
+    });
   });
 
   // --- 8. IMAGENES ---
@@ -1,6 +1,6 @@
 import { Injectable, NotFoundException, BadRequestException } from '@nestjs/common';
 import { InjectRepository } from '@nestjs/typeorm';
-import { Repository, In } from 'typeorm';
+import { Repository, In, MoreThanOrEqual } from 'typeorm';
 import { Product } from './entities/product.entity';
 import { CreateProductDto } from './dto/create-product.dto';
 import { CloudinaryService } from '../cloudinary/cloudinary.service';
@@ -100,7 +100,7 @@ export class ProductsService {
 
     if (!items || items.length === 0) {
         console.log('[Service] Lista vacía. Retornando false.');
-        return { valid: false, message: 'La lista de items está vacía' };
+        return { valid: false, message: 'La lista de items está vacía', items: [] };
     }
 
     try {
@@ -114,27 +114,36 @@ export class ProductsService {
       // LOG CRÍTICO 2: Después de la DB
       console.log(`[Service] DB respondió. Productos encontrados: ${products.length}`);
 
+      // Se revisan todos los items para que el cliente pueda ajustar cantidades línea a línea
+      let firstError: string | null = null;
+      const details: { productId: string; requested: number; available: number; found: boolean; valid: boolean }[] = [];
+
       for (const item of items) {
         const product = products.find((p) => p.id === item.productId);
 
         if (!product) {
           console.log(`[Service] Producto no encontrado: ${item.productId}`);
-          return { valid: false, message: `Producto ${item.productId} no encontrado` };
+          firstError = firstError ?? `Producto ${item.productId} no encontrado`;
+          details.push({ productId: item.productId, requested: item.quantity, available: 0, found: false, valid: false });
+          continue;
         }
 
         console.log(`[Service] Revisando ${product.name}. Stock: ${product.stock}, Pedido: ${item.quantity}`);
 
-        if (product.stock < item.quantity) {
+        const valid = product.stock >= item.quantity;
+        if (!valid) {
           console.log('[Service] Stock insuficiente.');
-          return {
-            valid: false, 
-            message: `Stock insuficiente para ${product.name}. Solicitado: ${item.quantity}, Disponible: ${product.stock}` 
-          };
+          firstError = firstError ?? `Stock insuficiente para ${product.name}. Solicitado: ${item.quantity}, Disponible: ${product.stock}`;
         }
+        details.push({ productId: item.productId, requested: item.quantity, available: product.stock, found: true, valid });
+      }
+
+      if (firstError) {
+        return { valid: false, message: firstError, items: details };
       }
 
       console.log('[Service] Todo OK. Stock disponible.');
-      return { valid: true, message: 'Stock disponible' };
+      return { valid: true, message: 'Stock disponible', items: details };
 
     } catch (error) {
       console.error("[Service] ERROR FATAL en DB o Lógica:", error);
@@ -172,27 +181,40 @@ export class ProductsService {
   }
 
   async decreaseStockBatch(items: { productId: string; quantity: number }[]) {
-    const productIds = items.map((item) => item.productId);
-    const products = await this.productRepo.findBy({ id: In(productIds) });
-    const productsToSave: Product[] = [];
-
+    // Las tallas/colores de un mismo producto descuentan del mismo stock
+    const totals = new Map<string, number>();
     for (const item of items) {
-      const product = products.find((p) => p.id === item.productId);
-
-      if (!product) throw new NotFoundException(`Producto ${item.productId} no encontrado`);
+      totals.set(item.productId, (totals.get(item.productId) ?? 0) + item.quantity);
+    }
 
-      if (product.stock < item.quantity) {
-          throw new BadRequestException(
-            `Stock insuficiente para ${product.name}. Solicitado: ${item.quantity}, Disponible: ${product.stock}`
-          );
+    // Cada descuento es condicional (stock >= cantidad) y el lote va en una transacción: dos checkouts
+    // concurrentes no pueden llevarse la misma última unidad y un fallo no deja el lote a medias
+    await this.productRepo.manager.transaction(async (manager) => {
+      for (const [productId, quantity] of totals) {
+        const result = await manager.decrement(Product, { id: productId, stock: MoreThanOrEqual(quantity) }, 'stock', quantity);
+        if (result.affected) continue;
+
+        const product = await manager.findOneBy(Product, { id: productId });
+        if (!product) throw new NotFoundException(`Producto ${productId} no encontrado`);
+        throw new BadRequestException(
+          `Stock insuficiente para ${product.name}. Solicitado: ${quantity}, Disponible: ${product.stock}`
+        );
       }
+    });
 
-      product.stock -= item.quantity;
-      productsToSave.push(product);
-    }
-
-    await this.productRepo.save(productsToSave);
     return { success: true, message: 'Stock actualizado correctamente' };
   }
 
+  async increaseStockBatch(items: { productId: string; quantity: number }[]) {
+    // Incremento atómico (stock = stock + n): leer y guardar pisaría un descuento concurrente
+    await this.productRepo.manager.transaction(async (manager) => {
+      for (const item of items) {
+        const result = await manager.increment(Product, { id: item.productId }, 'stock', item.quantity);
+        if (!result.affected) throw new NotFoundException(`Producto ${item.productId} no encontrado`);
+      }
+    });
+
+    return { success: true, message: 'Stock restaurado correctamente' };
+  }
+
 }
\ No newline at end of file